@@ -4,12 +4,19 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"gomificator/internal/imprt"
+	"gomificator/internal/models"
+	"gomificator/internal/rewards"
 	"gomificator/internal/settings"
 	"gomificator/internal/storage"
+	"gomificator/internal/utils"
+	"gomificator/internal/watch"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,25 +27,93 @@ import (
 )
 
 // autoimportCmd represents the autoimport command
-// TODO Добавь отображение модели статистики плюс её обновление каждый раз, когда происходит операция импорта
 var autoimportCmd = &cobra.Command{
 	Use:   "autoimport",
-	Short: "Auto-import newest JSON on interval",
-	Long:  `Watches autoimport.path and, every interval, imports the newest JSON backup until you quit.`,
+	Short: "Auto-import changed files on interval",
+	Long: `Follows every directory in autoimport.sources and, on each source's interval, imports
+the newest matching file (policy newest) or every changed one (policy all) until you quit.
+Files that didn't change since their last import are skipped. With watch: true
+the import also runs as soon as a file in the directory changes (Linux only).
+Below the sources today's statistics are shown and refreshed after every import.
+
+With --daemon no UI is shown and every run is logged to stderr, which suits a
+systemd user service. SIGHUP reloads the settings, SIGTERM stops the daemon.
+Only one autoimport runs at a time.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg, err := settings.LoadConfig(nil)
 		if err != nil {
 			panic(err)
 		}
 
-		m := makeAutoImportModel(cfg)
+		sourceOverride, err := cmd.Flags().GetString(SourceTypeFlag)
+		if err != nil {
+			panic(err)
+		}
+		if err := applyAutoImportSource(cfg, sourceOverride); err != nil {
+			panic(err)
+		}
+
+		lock, err := acquireAutoImportLock()
+		if err != nil {
+			fmt.Println("autoimport failed:", err)
+			os.Exit(1)
+		}
+		defer lock.Release()
+
+		if autoImportDaemon {
+			if err := runAutoImportDaemon(cfg, sourceOverride); err != nil {
+				slog.Error("autoimport stopped", "err", err)
+				lock.Release()
+				os.Exit(1)
+			}
+			return
+		}
+
+		watchers := make([]watch.Watcher, len(cfg.AutoImport.Sources))
+		for i, source := range cfg.AutoImport.Sources {
+			watchers[i], err = openAutoImportWatcher(source)
+			if err != nil {
+				// polling on the interval keeps working without events
+				fmt.Printf("%s: file events unavailable, polling instead: %v\n", source.Name, err)
+				continue
+			}
+			if watchers[i] != nil {
+				defer watchers[i].Close()
+			}
+		}
+
+		m := makeAutoImportModel(cfg, watchers)
 		if _, err := tea.NewProgram(m).Run(); err != nil {
 			fmt.Println("autoimport failed:", err)
+			lock.Release()
 			os.Exit(1)
 		}
 	},
 }
 
+// applyAutoImportSource replaces the importer of every source by the --source
+// flag if it was given and checks that the importers exist.
+func applyAutoImportSource(cfg *settings.Config, override string) error {
+	for i := range cfg.AutoImport.Sources {
+		source := &cfg.AutoImport.Sources[i]
+		if override != "" {
+			source.Source = override
+		}
+		if !slices.Contains(importerNames(), source.Source) {
+			return fmt.Errorf("%s: unknown importer type: %s", source.Name, source.Source)
+		}
+	}
+	return nil
+}
+
+// openAutoImportWatcher returns nil without an error if the source isn't watched.
+func openAutoImportWatcher(source settings.AutoImportSource) (watch.Watcher, error) {
+	if !source.Watch {
+		return nil, nil
+	}
+	return watch.New(source.Path)
+}
+
 // --- Bubble Tea model for autoimport ---
 
 type autoKeymap struct {
@@ -46,23 +121,35 @@ type autoKeymap struct {
 	run  key.Binding
 }
 
+type autoSourceState struct {
+	source  settings.AutoImportSource
+	watcher watch.Watcher
+	last    importResultMsg
+}
+
 type autoModel struct {
-	cfg       *settings.Config
-	interval  time.Duration
-	help      help.Model
-	keymap    autoKeymap
-	quitting  bool
-	lastRunAt time.Time
-	lastFile  string
-	lastCount int
-	lastErr   error
-}
-
-func makeAutoImportModel(cfg *settings.Config) tea.Model {
+	cfg      *settings.Config
+	sources  []autoSourceState
+	stats    *modelStatistics
+	statsErr error
+	help     help.Model
+	keymap   autoKeymap
+	quitting bool
+	// imports run one at a time, like in the daemon loop, queue holds the
+	// sources waiting for their turn
+	queue     []int
+	importing bool
+}
+
+func makeAutoImportModel(cfg *settings.Config, watchers []watch.Watcher) tea.Model {
+	sources := make([]autoSourceState, len(cfg.AutoImport.Sources))
+	for i, source := range cfg.AutoImport.Sources {
+		sources[i] = autoSourceState{source: source, watcher: watchers[i]}
+	}
 	return autoModel{
-		cfg:      cfg,
-		interval: cfg.AutoImport.Every,
-		help:     help.New(),
+		cfg:     cfg,
+		sources: sources,
+		help:    help.New(),
 		keymap: autoKeymap{
 			quit: key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
 			run:  key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "run now")),
@@ -70,19 +157,77 @@ func makeAutoImportModel(cfg *settings.Config) tea.Model {
 	}
 }
 
-type tickMsg time.Time
-type importResultMsg struct {
-	file  string
-	count int
+type statisticsMsg struct {
+	stats modelStatistics
 	err   error
-	at    time.Time
+}
+type tickMsg struct{ source int }
+type importRequestMsg struct{ source int }
+type fileChangedMsg struct{ source int }
+type importResultMsg struct {
+	source  int
+	file    string
+	files   int
+	count   int
+	saved   storage.SaveBatchResult
+	skipped bool
+	err     error
+	at      time.Time
+	// rewards changed by settling the imported days, settleErr doesn't fail the import
+	rewards   models.WalletModel
+	settleErr error
 }
 
 func (m autoModel) Init() tea.Cmd {
-	return tea.Batch(
-		m.doImport(),
-		tea.Tick(m.interval, func(t time.Time) tea.Msg { return tickMsg(t) }),
-	)
+	cmds := []tea.Cmd{m.refreshStatistics()}
+	for i := range m.sources {
+		cmds = append(cmds, requestImport(i), m.tick(i), m.waitForChange(i))
+	}
+	return tea.Batch(cmds...)
+}
+
+func requestImport(source int) tea.Cmd {
+	return func() tea.Msg { return importRequestMsg{source: source} }
+}
+
+// scheduleImport queues an import of the source unless it already waits and
+// starts it if no other import is running.
+func (m *autoModel) scheduleImport(source int) tea.Cmd {
+	if !slices.Contains(m.queue, source) {
+		m.queue = append(m.queue, source)
+	}
+	return m.nextImport()
+}
+
+// nextImport starts the first queued import once the running one is done.
+func (m *autoModel) nextImport() tea.Cmd {
+	if m.importing || len(m.queue) == 0 {
+		return nil
+	}
+	source := m.queue[0]
+	m.queue = m.queue[1:]
+	m.importing = true
+	return m.doImport(source)
+}
+
+func (m autoModel) tick(source int) tea.Cmd {
+	return tea.Tick(m.sources[source].source.Every, func(time.Time) tea.Msg { return tickMsg{source: source} })
+}
+
+// waitForChange blocks until the watcher of the source reports a change. It
+// does nothing in polling mode.
+func (m autoModel) waitForChange(source int) tea.Cmd {
+	watcher := m.sources[source].watcher
+	if watcher == nil {
+		return nil
+	}
+	events := watcher.Events()
+	return func() tea.Msg {
+		if _, ok := <-events; !ok {
+			return nil
+		}
+		return fileChangedMsg{source: source}
+	}
 }
 
 func (m autoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -93,16 +238,29 @@ func (m autoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.quitting = true
 			return m, tea.Quit
 		case key.Matches(msg, m.keymap.run):
-			return m, m.doImport()
+			var cmds []tea.Cmd
+			for i := range m.sources {
+				cmds = append(cmds, m.scheduleImport(i))
+			}
+			return m, tea.Batch(cmds...)
 		}
+	case importRequestMsg:
+		return m, m.scheduleImport(msg.source)
 	case tickMsg:
 		// schedule import and next tick
-		return m, tea.Batch(m.doImport(), tea.Tick(m.interval, func(t time.Time) tea.Msg { return tickMsg(t) }))
+		return m, tea.Batch(m.scheduleImport(msg.source), m.tick(msg.source))
+	case fileChangedMsg:
+		return m, tea.Batch(m.scheduleImport(msg.source), m.waitForChange(msg.source))
 	case importResultMsg:
-		m.lastRunAt = msg.at
-		m.lastFile = msg.file
-		m.lastCount = msg.count
-		m.lastErr = msg.err
+		m.sources[msg.source].last = msg
+		m.importing = false
+		return m, tea.Batch(m.refreshStatistics(), m.nextImport())
+	case statisticsMsg:
+		if msg.err != nil {
+			m.statsErr = msg.err
+			return m, nil
+		}
+		m.stats, m.statsErr = &msg.stats, nil
 		return m, nil
 	}
 	return m, nil
@@ -110,75 +268,225 @@ func (m autoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m autoModel) View() string {
 	s := "Autoimport running\n"
-	s += fmt.Sprintf("Dir: %s\n", m.cfg.AutoImport.Path)
-	s += fmt.Sprintf("Every: %s\n", m.interval)
-	if !m.lastRunAt.IsZero() {
-		if m.lastErr != nil {
-			s += fmt.Sprintf("Last: %s ERROR: %v\n", m.lastRunAt.Format(time.RFC3339), m.lastErr)
+	for _, state := range m.sources {
+		source := state.source
+		s += fmt.Sprintf("\n%s\n", source.Name)
+		s += fmt.Sprintf("  Files: %s (%s)\n", filepath.Join(source.Path, source.Glob), source.Policy)
+		s += fmt.Sprintf("  Source: %s\n", source.Source)
+		s += fmt.Sprintf("  Every: %s\n", source.Every)
+		if state.watcher != nil {
+			s += "  Watching for file changes\n"
+		}
+
+		last := state.last
+		if last.at.IsZero() {
+			continue
+		}
+		file := filepath.Base(last.file)
+		if source.Policy == settings.AutoImportAll {
+			file = fmt.Sprintf("%d files", last.files)
+		}
+		if last.err != nil {
+			s += fmt.Sprintf("  Last: %s ERROR: %v\n", last.at.Format(time.RFC3339), last.err)
+		} else if last.skipped {
+			s += fmt.Sprintf("  Last: %s file=%s unchanged, skipped\n", last.at.Format(time.RFC3339), filepath.Base(last.file))
 		} else {
-			s += fmt.Sprintf("Last: %s file=%s imported=%d\n", m.lastRunAt.Format(time.RFC3339), filepath.Base(m.lastFile), m.lastCount)
+			s += fmt.Sprintf("  Last: %s file=%s imported=%d inserted=%d updated=%d\n",
+				last.at.Format(time.RFC3339), file, last.count, last.saved.Inserted, last.saved.Updated)
+		}
+		if last.settleErr != nil {
+			s += fmt.Sprintf("  Rewards: ERROR: %v\n", last.settleErr)
+		} else if len(last.rewards) > 0 {
+			s += fmt.Sprintf("  Rewards: %s\n", formatWalletDelta(last.rewards))
 		}
 	}
+	if m.statsErr != nil {
+		s += fmt.Sprintf("\nStatistics ERROR: %v\n", m.statsErr)
+	} else if m.stats != nil {
+		s += "\n" + m.stats.View()
+	}
 	if !m.quitting {
 		s += "\n" + m.help.ShortHelpView([]key.Binding{m.keymap.run, m.keymap.quit})
 	}
 	return s
 }
 
-func (m autoModel) doImport() tea.Cmd {
-	dir := m.cfg.AutoImport.Path
-	pattern := filepath.Join(dir, "*.json")
+// refreshStatistics rebuilds the statistics panel, it runs after every import.
+func (m autoModel) refreshStatistics() tea.Cmd {
+	cfg := *m.cfg
 	return func() tea.Msg {
-		matches, err := filepath.Glob(pattern)
+		strg, err := storage.NewSqlliteStorage()
 		if err != nil {
-			return importResultMsg{err: fmt.Errorf("glob %s: %w", pattern, err), at: time.Now()}
+			return statisticsMsg{err: err}
 		}
-		if len(matches) == 0 {
-			return importResultMsg{err: fmt.Errorf("no JSON files found in %s", dir), at: time.Now()}
+		stats, err := buildStatisticsModel(cfg, strg, timerFilter{})
+		return statisticsMsg{stats: stats, err: err}
+	}
+}
+
+func (m autoModel) doImport(index int) tea.Cmd {
+	source := m.sources[index].source
+	cfg := m.cfg
+	return func() tea.Msg {
+		result := runAutoImport(cfg, source)
+		result.source = index
+		return result
+	}
+}
+
+type autoImportFile struct {
+	path string
+	info os.FileInfo
+}
+
+// autoImportCandidates lists the files of the source to import, oldest first.
+func autoImportCandidates(source settings.AutoImportSource) ([]autoImportFile, error) {
+	pattern := filepath.Join(source.Path, source.Glob)
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob %s: %w", pattern, err)
+	}
+
+	var files []autoImportFile
+	for _, p := range matches {
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
 		}
-		var newestPath string
-		var newestMod int64
-		for _, p := range matches {
-			if !strings.EqualFold(filepath.Ext(p), ".json") {
-				continue
-			}
-			info, err := os.Stat(p)
-			if err != nil || !info.Mode().IsRegular() {
-				continue
-			}
-			mt := info.ModTime().UnixNano()
-			if mt > newestMod || newestPath == "" {
-				newestMod = mt
-				newestPath = p
-			}
+		files = append(files, autoImportFile{path: p, info: info})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files matching %s", pattern)
+	}
+
+	slices.SortFunc(files, func(a, b autoImportFile) int {
+		return a.info.ModTime().Compare(b.info.ModTime())
+	})
+	if source.Policy == settings.AutoImportNewest {
+		files = files[len(files)-1:]
+	}
+	return files, nil
+}
+
+// runAutoImport imports the files picked by the source policy, skipping
+// the ones already imported with the same content, and settles the rewards
+// of the days it changed.
+func runAutoImport(cfg *settings.Config, source settings.AutoImportSource) importResultMsg {
+	files, err := autoImportCandidates(source)
+	if err != nil {
+		return importResultMsg{err: err, at: time.Now()}
+	}
+
+	storageService, err := storage.NewSqlliteStorage()
+	if err != nil {
+		return importResultMsg{err: err, at: time.Now()}
+	}
+
+	result := importResultMsg{skipped: true}
+	var errs []error
+	var touched []time.Time
+	for _, file := range files {
+		result.file = file.path
+		fileResult, days := importAutoImportFile(storageService, file, source.Source, cfg.Importers)
+		if fileResult.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(file.path), fileResult.err))
+			continue
 		}
-		if newestPath == "" {
-			return importResultMsg{err: fmt.Errorf("no regular JSON files found in %s", dir), at: time.Now()}
+		if fileResult.skipped {
+			continue
 		}
+		result.skipped = false
+		result.files++
+		result.count += fileResult.count
+		result.saved.Inserted += fileResult.saved.Inserted
+		result.saved.Updated += fileResult.saved.Updated
+		result.saved.Unchanged += fileResult.saved.Unchanged
+		touched = mergeDays(touched, days)
+	}
+	report, err := rewards.NewService(cfg, storageService).SettleImported(context.Background(), touched)
+	result.rewards, result.settleErr = report.Delta, err
+	result.err = errors.Join(errs...)
+	result.at = time.Now()
+	return result
+}
 
-		f, err := os.Open(newestPath)
-		if err != nil {
-			return importResultMsg{err: fmt.Errorf("open newest file: %w", err), at: time.Now()}
+// importAutoImportFile imports one file and returns the days it changed.
+func importAutoImportFile(strg *storage.Storage, file autoImportFile, importerName string, importersCfg settings.ImportersConfig) (importResultMsg, []time.Time) {
+	// cheap size and mtime check first, then the content hash
+	previous, err := strg.ImportedFilesRepo.Get(file.path)
+	if err != nil {
+		return importResultMsg{err: err}, nil
+	}
+	if previous != nil && previous.Size == file.info.Size() && previous.ModTime.Equal(file.info.ModTime()) {
+		return importResultMsg{skipped: true}, nil
+	}
+	hash, err := utils.FileSHA256(file.path)
+	if err != nil {
+		return importResultMsg{err: fmt.Errorf("hash file: %w", err)}, nil
+	}
+	fileRecord := models.ImportedFileModel{
+		Path:        file.path,
+		Size:        file.info.Size(),
+		ModTime:     file.info.ModTime(),
+		ContentHash: hash,
+	}
+	if previous != nil && previous.ContentHash == hash {
+		if err := strg.ImportedFilesRepo.Save(fileRecord); err != nil {
+			return importResultMsg{err: err}, nil
 		}
-		defer f.Close()
+		return importResultMsg{skipped: true}, nil
+	}
 
-		importer := imprt.NewImporterFromSuperProductivityBackupFile(f)
-		timers, err := importer.Import()
-		if err != nil {
-			return importResultMsg{file: newestPath, err: err, at: time.Now()}
+	batch, err := importInput(importerName, file.path, importersCfg)
+	if err != nil {
+		return importResultMsg{err: err}, nil
+	}
+
+	runSource := importerName
+	if batch.detected != "" {
+		runSource = batch.detected
+	}
+	runId, err := strg.ImportRunsRepo.Start(runSource, file.path)
+	if err != nil {
+		return importResultMsg{err: err}, nil
+	}
+	saved, completions, err := saveAutoImportBatch(strg, runId, batch, fileRecord)
+	if err != nil {
+		// nothing of the run was saved, it is kept in the history with its error
+		if finishErr := strg.ImportRunsRepo.Finish(runId, storage.SaveBatchResult{}, 0, err); finishErr != nil {
+			err = errors.Join(err, finishErr)
 		}
+		return importResultMsg{err: err}, nil
+	}
 
-		storageService, err := storage.NewSqlliteStorage()
+	days, err := strg.ImportRunsRepo.Days(runId)
+	if err != nil {
+		return importResultMsg{err: err}, nil
+	}
+	return importResultMsg{count: len(batch.timers), saved: saved}, mergeDays(days, completions.Days)
+}
+
+// saveAutoImportBatch saves the batch and finishes its run in one
+// transaction, a failure leaves nothing of it behind.
+func saveAutoImportBatch(strg *storage.Storage, runId int, batch importBatch, fileRecord models.ImportedFileModel) (storage.SaveBatchResult, storage.CompletionsSaveResult, error) {
+	var saved storage.SaveBatchResult
+	var completions storage.CompletionsSaveResult
+	err := strg.InTx(func(tx *storage.Storage) error {
+		var err error
+		saved, err = tx.TimersRepo.SaveBatchInRun(runId, batch.timers)
 		if err != nil {
-			return importResultMsg{file: newestPath, err: err, at: time.Now()}
+			return err
 		}
-		for _, timer := range timers {
-			if _, err := storageService.TimersRepo.Save(timer); err != nil {
-				return importResultMsg{file: newestPath, err: err, at: time.Now()}
-			}
+		completions, err = tx.CompletionsRepo.SaveBatchInRun(runId, batch.completions)
+		if err != nil {
+			return err
 		}
-		return importResultMsg{file: newestPath, count: len(timers), at: time.Now()}
-	}
+		if err := tx.ImportedFilesRepo.Save(fileRecord); err != nil {
+			return err
+		}
+		return tx.ImportRunsRepo.Finish(runId, saved, 0, nil)
+	})
+	return saved, completions, err
 }
 
 func init() {
@@ -193,4 +501,10 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// autoimportCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	autoimportCmd.Flags().StringP(SourceTypeFlag, "S", "",
+		fmt.Sprintf("Type of watched files, overrides the source of every autoimport source (%s)", strings.Join(importerNames(), ", ")))
+	autoimportCmd.RegisterFlagCompletionFunc(SourceTypeFlag, completeImporterNames)
+	autoimportCmd.Flags().BoolVar(&autoImportDaemon, "daemon", false, "Run without UI and log every import, for systemd or cron")
+	autoimportCmd.Flags().IntVar(&autoImportMaxFailures, "max-failures", 5, "With --daemon, exit with an error after this many failed runs in a row (0 never exits)")
+	autoimportCmd.Flags().StringVar(&autoImportLogFormat, "log-format", "text", "With --daemon, log format (text, json)")
 }
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"gomificator/internal/pidfile"
+	"gomificator/internal/settings"
+	"gomificator/internal/utils"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const autoImportLockFile = "autoimport.pid"
+
+var (
+	autoImportDaemon      bool
+	autoImportMaxFailures int
+	autoImportLogFormat   string
+)
+
+// acquireAutoImportLock keeps two autoimports from writing the same database.
+func acquireAutoImportLock() (*pidfile.PidFile, error) {
+	appDataLocation, err := utils.EnsureAppDataLocation()
+	if err != nil {
+		return nil, fmt.Errorf("ensure app data location: %w", err)
+	}
+	lock, err := pidfile.Acquire(filepath.Join(appDataLocation, autoImportLockFile))
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock: %w", err)
+	}
+	return lock, nil
+}
+
+func newAutoImportLogger(format string) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
+
+// autoImportTrigger asks the daemon loop to import a source.
+type autoImportTrigger struct {
+	source int
+	reason string
+}
+
+// runAutoImportDaemon imports on every interval and file event until SIGTERM
+// or SIGINT. It returns an error after --max-failures failed runs of a source
+// in a row.
+func runAutoImportDaemon(cfg *settings.Config, sourceOverride string) error {
+	logger, err := newAutoImportLogger(autoImportLogFormat)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	// imports run one at a time in this loop, sources only send triggers
+	triggers := make(chan autoImportTrigger)
+	// follow starts a goroutine per source and returns the function stopping them
+	follow := func() func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		var followers sync.WaitGroup
+		for i, source := range cfg.AutoImport.Sources {
+			logger.Info("following source",
+				"source", source.Name,
+				"files", filepath.Join(source.Path, source.Glob),
+				"importer", source.Source,
+				"policy", source.Policy,
+				"every", source.Every.String(),
+				"watch", source.Watch,
+			)
+			followers.Add(1)
+			go func() {
+				defer followers.Done()
+				followAutoImportSource(ctx, logger, i, source, triggers)
+			}()
+		}
+		return func() {
+			cancel()
+			followers.Wait()
+		}
+	}
+
+	logger.Info("autoimport started", "pid", os.Getpid(), "sources", len(cfg.AutoImport.Sources))
+	stop := follow()
+	defer func() { stop() }()
+
+	failures := make(map[string]int)
+	for {
+		select {
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				logger.Info("autoimport stopped", "signal", sig.String())
+				return nil
+			}
+
+			reloaded, err := settings.LoadConfig(nil)
+			if err == nil {
+				err = applyAutoImportSource(reloaded, sourceOverride)
+			}
+			if err != nil {
+				// a broken settings file must not stop a running service
+				logger.Error("reload settings failed, keeping the previous ones", "err", err)
+				continue
+			}
+
+			stop()
+			cfg = reloaded
+			clear(failures)
+			logger.Info("settings reloaded", "sources", len(cfg.AutoImport.Sources))
+			stop = follow()
+		case trigger := <-triggers:
+			source := cfg.AutoImport.Sources[trigger.source]
+			startedAt := time.Now()
+			result := runAutoImport(cfg, source)
+			if result.err != nil {
+				failures[source.Name]++
+				logger.Error("import failed",
+					"source", source.Name,
+					"trigger", trigger.reason,
+					"err", result.err,
+					"failures", failures[source.Name],
+				)
+				if autoImportMaxFailures > 0 && failures[source.Name] >= autoImportMaxFailures {
+					return fmt.Errorf("%s: %d imports failed in a row, last: %w", source.Name, failures[source.Name], result.err)
+				}
+				continue
+			}
+
+			failures[source.Name] = 0
+			if result.settleErr != nil {
+				logger.Warn("reward settlement failed, days queued for fix-rewards --pending", "source", source.Name, "err", result.settleErr)
+			} else if len(result.rewards) > 0 {
+				logger.Info("rewards settled", "source", source.Name, "rewards", formatWalletDelta(result.rewards))
+			}
+			if result.skipped {
+				logger.Info("import skipped", "source", source.Name, "trigger", trigger.reason, "file", result.file, "reason", "unchanged")
+				continue
+			}
+			logger.Info("import done",
+				"source", source.Name,
+				"trigger", trigger.reason,
+				"file", result.file,
+				"files", result.files,
+				"timers", result.count,
+				"inserted", result.saved.Inserted,
+				"updated", result.saved.Updated,
+				"unchanged", result.saved.Unchanged,
+				"duration", time.Since(startedAt).String(),
+			)
+		}
+	}
+}
+
+// followAutoImportSource sends a trigger at start, on every interval and on
+// file events until ctx is done.
+func followAutoImportSource(ctx context.Context, logger *slog.Logger, index int, source settings.AutoImportSource, triggers chan<- autoImportTrigger) {
+	ticker := time.NewTicker(source.Every)
+	defer ticker.Stop()
+
+	var events <-chan struct{}
+	watcher, err := openAutoImportWatcher(source)
+	if err != nil {
+		// the interval keeps working without events
+		logger.Warn("file events unavailable, polling instead", "source", source.Name, "err", err)
+	} else if watcher != nil {
+		defer watcher.Close()
+		events = watcher.Events()
+	}
+
+	send := func(reason string) bool {
+		select {
+		case triggers <- autoImportTrigger{source: index, reason: reason}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if !send("start") {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !send("interval") {
+				return
+			}
+		case _, ok := <-events:
+			if !ok {
+				logger.Warn("file watcher stopped, polling only", "source", source.Name)
+				events = nil
+				continue
+			}
+			if !send("file change") {
+				return
+			}
+		}
+	}
+}
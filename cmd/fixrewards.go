@@ -1,33 +1,52 @@
 package cmd
 
 import (
+    "context"
     "fmt"
     "gomificator/internal/constnats"
     "gomificator/internal/models"
+    "gomificator/internal/rewards"
     "gomificator/internal/settings"
     "gomificator/internal/storage"
+    "os"
+    "slices"
     "time"
 
     "github.com/spf13/cobra"
 )
 
 var (
-    fixRewardsDate string
-    fixRewardsFrom string
-    fixRewardsTo   string
+    fixRewardsDate    string
+    fixRewardsFrom    string
+    fixRewardsTo      string
+    fixRewardsPending bool
+    fixRewardsAll     bool
+    fixRewardsDryRun  bool
+    fixRewardsReapply bool
 )
 
 // fixRewardsCmd represents the command to fix rewards for a specific date
 var fixRewardsCmd = &cobra.Command{
     Use:   "fix-rewards",
     Short: "Fix rewards for a date or range",
-    Long:  `Calculates focus minutes for the given date or date range and updates the wallet with earned medals based on your settings goals.`,
+    Long:  `Calculates focus minutes and completed tasks for the given date, date range, the queued pending days or
+the whole history and updates the wallet with earned medals based on your settings goals.
+Weeks and months that are over are settled against the weekly and monthly goals as well.
+With --dry-run the earned medals are compared with the recorded ones and nothing is saved.
+Days settled before keep the goals they were settled with, --reapply-rules evaluates them
+against the current settings. Penalties of the day types are taken from the wallet down to
+penaltyfloor at most. The XP of the days is recorded with the rewards, days stored before
+XP existed get it with the first automatic settlement, their weeks and months once they are
+fixed again, e.g. with --all.`,
     Run: func(cmd *cobra.Command, args []string) {
-        // Validate flags: either --date OR both --from and --to
-        singleMode := fixRewardsDate != "" && fixRewardsFrom == "" && fixRewardsTo == ""
-        rangeMode := fixRewardsDate == "" && fixRewardsFrom != "" && fixRewardsTo != ""
-        if !singleMode && !rangeMode {
-            panic("specify either --date YYYY-MM-DD or both --from and --to (YYYY-MM-DD)")
+        // Validate flags: either --date OR both --from and --to OR --pending OR --all
+        noDates := fixRewardsDate == "" && fixRewardsFrom == "" && fixRewardsTo == ""
+        singleMode := fixRewardsDate != "" && fixRewardsFrom == "" && fixRewardsTo == "" && !fixRewardsPending && !fixRewardsAll
+        rangeMode := fixRewardsDate == "" && fixRewardsFrom != "" && fixRewardsTo != "" && !fixRewardsPending && !fixRewardsAll
+        pendingMode := noDates && fixRewardsPending && !fixRewardsAll
+        allMode := noDates && fixRewardsAll && !fixRewardsPending
+        if !singleMode && !rangeMode && !pendingMode && !allMode {
+            panic("specify either --date YYYY-MM-DD, both --from and --to (YYYY-MM-DD), --pending or --all")
         }
 
         cfg, err := settings.LoadConfig(nil)
@@ -40,82 +59,39 @@ var fixRewardsCmd = &cobra.Command{
             panic(err)
         }
 
-        // Accumulate delta across days, apply once at end
-        accumulatedDelta := make(models.WalletModel)
+        svc := rewards.NewService(cfg, strg)
+        svc.SetReapplyRules(fixRewardsReapply)
+        ctx := context.Background()
 
-        processDay := func(d time.Time) {
-            dayType, ok := cfg.Celendar[d.Weekday()]
-            if !ok {
-                fmt.Printf("%s: skipped (no day type configured)\n", d.Format(constnats.DateLayout))
-                return
-            }
-
-            timers, err := strg.TimersRepo.GetTimersBetweenDates(d, d)
+        var days []time.Time
+        if pendingMode {
+            queued, err := strg.PendingDaysRepo.List()
             if err != nil {
                 panic(err)
             }
-
-            total := time.Duration(0)
-            for _, t := range timers {
-                total += t.SecondsSpent
+            // today and later days stay queued until they are over
+            var open []time.Time
+            days, open = svc.SplitClosed(queued)
+            if len(days) == 0 {
+                fmt.Println("No days waiting for recalculation")
             }
-            minutes := int(total.Minutes())
-
-            // Calculate earned medals for the day (new state)
-            earned := make(models.WalletModel)
-            for _, goal := range dayType.FocusGoals {
-                if minutes >= goal.Minutes {
-                    earned[goal.Medal] += goal.Count
-                }
+            if len(open) > 0 {
+                fmt.Printf("%d queued days from today on wait until they are over\n", len(open))
             }
-
-            // Load previous daily state and compute delta
-            prev, err := strg.RewardsRepo.LoadByDate(d)
+        } else if allMode {
+            days, err = svc.History(rewards.Today())
             if err != nil {
                 panic(err)
             }
-            // delta = earned(new) - prev(old)
-            unionKeys := make(map[constnats.Medal]struct{})
-            for m := range earned { unionKeys[m] = struct{}{} }
-            for m := range prev { unionKeys[m] = struct{}{} }
-            delta := make(models.WalletModel)
-            for m := range unionKeys {
-                delta[m] = earned[m] - prev[m]
+            if len(days) == 0 {
+                fmt.Println("No days with data before today")
             }
-
-            // Replace per-day record to ensure idempotency
-            if err := strg.RewardsRepo.ReplaceForDate(d, earned); err != nil {
-                panic(err)
-            }
-
-            // Print per-day summary and accumulate
-            if len(earned) == 0 {
-                fmt.Printf("%s: no rewards earned (%d minutes)\n", d.Format(constnats.DateLayout), minutes)
-            } else {
-                fmt.Printf("%s: fixed %d minutes; rewards: ", d.Format(constnats.DateLayout), minutes)
-                first := true
-                for medal, cnt := range earned {
-                    if !first { fmt.Print(", ") }
-                    fmt.Printf("%d %s", cnt, medal)
-                    first = false
-                }
-                fmt.Println()
-            }
-
-            // Accumulate delta for wallet update
-            for medal, dcnt := range delta {
-                if dcnt != 0 {
-                    accumulatedDelta[medal] += dcnt
-                }
-            }
-        }
-
-        if singleMode {
+        } else if singleMode {
             d, err := time.Parse(constnats.DateLayout, fixRewardsDate)
             if err != nil {
                 panic(fmt.Errorf("parse --date: %w", err))
             }
-            processDay(d)
+            days = []time.Time{d}
         } else { // rangeMode
             start, err := time.Parse(constnats.DateLayout, fixRewardsFrom)
             if err != nil {
@@ -129,34 +105,78 @@ var fixRewardsCmd = &cobra.Command{
                 panic("--to must be on or after --from")
             }
             for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
-                processDay(d)
+                days = append(days, d)
             }
         }
 
-        if len(accumulatedDelta) == 0 {
-            // Nothing to save
+        if fixRewardsDryRun {
+            report, err := svc.PreviewDays(ctx, days)
+            if err != nil {
+                panic(err)
+            }
+            for _, day := range report.Days {
+                printDayPreview(day)
+            }
+            for _, period := range report.Periods {
+                printPeriodPreview(period)
+            }
+            if len(report.Streak.Delta) > 0 {
+                fmt.Printf("Streak milestones: earned %s, recorded %s: %s\n",
+                    formatWallet(report.Streak.Earned), formatWallet(report.Streak.Previous), formatWalletDelta(report.Streak.Delta))
+            }
+            if penalty := totalPenalty(report.Days); len(penalty) > 0 {
+                fmt.Printf("Penalties: %s\n", formatWalletDelta(penalty))
+            }
+            if len(report.Delta) == 0 {
+                fmt.Println("Net wallet change: none")
+            } else {
+                fmt.Printf("Net wallet change: %s\n", formatWalletDelta(report.Delta))
+            }
+            rulesChanged := slices.ContainsFunc(report.Days, func(d rewards.DayResult) bool { return d.RulesChanged }) ||
+                slices.ContainsFunc(report.Periods, func(p rewards.PeriodResult) bool { return p.RulesChanged })
+            if !fixRewardsReapply && rulesChanged {
+                fmt.Println("Days marked * keep the rules they were settled with, use --reapply-rules for the current settings")
+            }
+            fmt.Println("Dry run, nothing was saved")
             return
         }
 
-        // Load wallet, apply increments, and save once
-        wallet, err := strg.WalletRepo.Load()
+        report, err := svc.SettleDays(ctx, days)
         if err != nil {
             panic(err)
         }
-        if wallet == nil {
-            wallet = make(models.WalletModel)
+        if pendingMode {
+            // queued days are done once the wallet is saved
+            if err := strg.PendingDaysRepo.Remove(days); err != nil {
+                panic(err)
+            }
         }
-        for medal, cnt := range accumulatedDelta {
-            wallet[medal] = wallet[medal] + cnt
+
+        for _, day := range report.Days {
+            printDayRewards(day)
         }
-        if err := strg.WalletRepo.Save(wallet); err != nil {
-            panic(err)
+        for _, period := range report.Periods {
+            printPeriodRewards(period)
+        }
+        if len(report.Streak.Delta) > 0 {
+            fmt.Printf("Streak milestones: %s (current streak %d days, best %d)\n",
+                formatWalletDelta(report.Streak.Delta), report.Streak.Current, report.Streak.Best)
+        }
+        if penalty := totalPenalty(report.Days); len(penalty) > 0 {
+            fmt.Printf("Penalties: %s\n", formatWalletDelta(penalty))
+        }
+
+        printLevelUps(os.Stdout, report.LevelUps)
+
+        if len(report.Delta) == 0 {
+            // Nothing was saved
+            return
         }
 
         // Print total summary
         fmt.Printf("Total rewards added: ")
         first := true
-        for medal, cnt := range accumulatedDelta {
+        for medal, cnt := range report.Delta {
             if !first { fmt.Print(", ") }
             fmt.Printf("%d %s", cnt, medal)
             first = false
@@ -165,9 +185,125 @@ var fixRewardsCmd = &cobra.Command{
     },
 }
 
+// printPeriodRewards prints the summary of a week or month closed by fix-rewards.
+func printPeriodRewards(period rewards.PeriodResult) {
+    fmt.Printf("%s: %d minutes, %d days with a goal met; rewards: %s\n",
+        periodName(period), period.Minutes, period.GoalDays, formatWallet(period.Earned))
+}
+
+// printPeriodPreview is printDayPreview for weeks and months.
+func printPeriodPreview(period rewards.PeriodResult) {
+    name := periodName(period)
+    if period.RulesChanged {
+        name += "*"
+    }
+    change := "no change"
+    if len(period.Delta) > 0 {
+        change = formatWalletDelta(period.Delta)
+    }
+    fmt.Printf("%s: %d minutes, %d days with a goal met; earned %s, recorded %s: %s\n",
+        name, period.Minutes, period.GoalDays, formatWallet(period.Earned), formatWallet(period.Previous), change)
+}
+
+// periodName is like "week 2025-11-03..2025-11-09".
+func periodName(period rewards.PeriodResult) string {
+    return fmt.Sprintf("%s %s..%s", period.Kind, period.Start.Format(constnats.DateLayout), period.End.Format(constnats.DateLayout))
+}
+
+// printDayPreview prints what fix-rewards --dry-run would record for a day
+// next to what is recorded now.
+func printDayPreview(day rewards.DayResult) {
+    date := day.Day.Format(constnats.DateLayout)
+    if day.Skipped {
+        fmt.Printf("%s: skipped (no day type configured)\n", date)
+        return
+    }
+    change := "no change"
+    if len(day.Delta) > 0 {
+        change = formatWalletDelta(day.Delta)
+    }
+    if day.RulesChanged {
+        date += "*"
+    }
+    late := ""
+    if day.LateGoals > 0 {
+        late = fmt.Sprintf(" (%d goals late)", day.LateGoals)
+    }
+    recorded := formatWallet(day.Previous)
+    if len(day.PreviousPenalty) > 0 {
+        recorded += ", penalty " + formatWalletDelta(day.PreviousPenalty)
+    }
+    fmt.Printf("%s: %d minutes, %d tasks done; earned %s%s%s, recorded %s: %s\n",
+        date, day.Minutes, day.TasksDone, formatWallet(day.Earned), late, formatPenalty(day), recorded, change)
+}
+
+// formatPenalty describes the penalties applied to a day, like
+// ", penalty -1 bronze (cut by the wallet floor), shop blocked through 2025-11-05".
+func formatPenalty(day rewards.DayResult) string {
+    out := ""
+    if len(day.Penalty) > 0 {
+        out += ", penalty " + formatWalletDelta(day.Penalty)
+        if day.PenaltyFloored {
+            out += " (cut by the wallet floor)"
+        }
+    } else if day.PenaltyFloored {
+        out += ", penalty cut by the wallet floor"
+    }
+    if day.ShopBlockedUntil != nil {
+        out += ", shop blocked through " + day.ShopBlockedUntil.AddDate(0, 0, -1).Format(constnats.DateLayout)
+    }
+    return out
+}
+
+// totalPenalty sums the medals taken by the penalties of the days.
+func totalPenalty(days []rewards.DayResult) models.WalletModel {
+    total := make(models.WalletModel)
+    for _, day := range days {
+        for medal, cnt := range day.Penalty {
+            total[medal] += cnt
+        }
+    }
+    return total
+}
+
+// printDayRewards prints the per-day summary of fix-rewards.
+func printDayRewards(day rewards.DayResult) {
+    date := day.Day.Format(constnats.DateLayout)
+    if day.Skipped {
+        fmt.Printf("%s: skipped (no day type configured)\n", date)
+        return
+    }
+    late := ""
+    if day.LateGoals > 0 {
+        late = fmt.Sprintf("; %d goals reached after rest time", day.LateGoals)
+    }
+    if day.Status == models.DayFrozen {
+        late += "; streak kept by a freeze"
+    }
+    if penalty := formatPenalty(day); penalty != "" {
+        late += ";" + penalty[1:]
+    }
+    if len(day.Earned) == 0 {
+        fmt.Printf("%s: no rewards earned (%d minutes, %d tasks done)%s\n", date, day.Minutes, day.TasksDone, late)
+        return
+    }
+    fmt.Printf("%s: fixed %d minutes, %d tasks done; rewards: ", date, day.Minutes, day.TasksDone)
+    first := true
+    for medal, cnt := range day.Earned {
+        if !first { fmt.Print(", ") }
+        fmt.Printf("%d %s", cnt, medal)
+        first = false
+    }
+    fmt.Println(late)
+}
+
 func init() {
     rootCmd.AddCommand(fixRewardsCmd)
     fixRewardsCmd.Flags().StringVar(&fixRewardsDate, "date", "", "Date to fix rewards for (YYYY-MM-DD)")
     fixRewardsCmd.Flags().StringVar(&fixRewardsFrom, "from", "", "Start date (inclusive) for range mode (YYYY-MM-DD)")
     fixRewardsCmd.Flags().StringVar(&fixRewardsTo, "to", "", "End date (inclusive) for range mode (YYYY-MM-DD)")
+    fixRewardsCmd.Flags().BoolVar(&fixRewardsPending, "pending", false, "Fix the days queued by import undo and reconciliation")
+    fixRewardsCmd.Flags().BoolVar(&fixRewardsAll, "all", false, "Fix every day with data from the first timer up to yesterday")
+    fixRewardsCmd.Flags().BoolVar(&fixRewardsDryRun, "dry-run", false, "Show earned vs. recorded medals and the wallet change without saving anything")
+    fixRewardsCmd.Flags().BoolVar(&fixRewardsReapply, "reapply-rules", false, "Evaluate settled days against the current goals instead of the ones they were settled with")
 }
@@ -4,14 +4,32 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"gomificator/internal/constnats"
 	"gomificator/internal/imprt"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
 	"gomificator/internal/storage"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	importDryRun     bool
+	importDetails    bool
+	importReconcile  bool
+	importHardDelete bool
+)
+
 const (
 	FileDestFlag   = "flile"
 	SourceTypeFlag = "source"
@@ -29,60 +47,447 @@ var importCmd = &cobra.Command{
 			panic(err)
 		}
 
-		importerTypeStr, err := cmd.Flags().GetString(SourceTypeFlag)
+		importerName, err := cmd.Flags().GetString(SourceTypeFlag)
 		if err != nil {
 			panic(err)
 		}
 
-		importerType, err := imprt.GetImporterTypeByString(importerTypeStr)
+		cfg, err := settings.LoadConfig(nil)
 		if err != nil {
 			panic(err)
 		}
 
-		file, err := os.Open(fileDest)
+		inputs, err := collectImportInputs(fileDest, importerName)
 		if err != nil {
 			panic(err)
 		}
 
-		var importer imprt.Importer
-		switch importerType {
-		case imprt.ImporterTypeSuperProductivityExport:
-			importer = imprt.NewImporterFromSuperProductivityExportFile(file)
-		case imprt.ImporterTypeSuperProductivityBackup:
-			importer = imprt.NewImporterFromSuperProductivityBackupFile(file)
-		default:
-			panic(fmt.Sprintf("unsupported importer type: %s", importerType))
+		var batch importBatch
+		var runSources []string
+		for i, input := range inputs {
+			inputBatch, err := importInput(importerName, input, cfg.Importers)
+			if err != nil {
+				panic(err)
+			}
+			runSource := importerName
+			if inputBatch.detected != "" {
+				fmt.Printf("Detected %s source in %s\n", inputBatch.detected, input)
+				runSource = inputBatch.detected
+			}
+			if !slices.Contains(runSources, runSource) {
+				runSources = append(runSources, runSource)
+			}
+			batch.merge(inputBatch, i == 0)
 		}
+		timers := batch.timers
 
-		timers, err := importer.Import()
-		if err != nil {
-			panic(err)
-		}
 		fmt.Printf("Imported %d timers\n", len(timers))
 		storageService, err := storage.NewSqlliteStorage()
 		if err != nil {
 			panic(err)
 		}
 
-		for _, timer := range timers {
-			id, err := storageService.TimersRepo.Save(timer)
+		var deletions []imprt.TimerChange
+		if importReconcile {
+			deletions, err = reconcileImportedTimers(storageService, batch)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		if importDryRun {
+			changes, err := diffImportedTimers(storageService, timers)
 			if err != nil {
 				panic(err)
 			}
+			printImportDiff(os.Stdout, append(changes, deletions...), importDetails)
+			if len(batch.completions) > 0 {
+				fmt.Printf("Completed tasks in source: %d\n", len(batch.completions))
+			}
+			printAffectedDates(os.Stdout, affectedDates(deletions), false)
+			return
+		}
+
+		runId, err := storageService.ImportRunsRepo.Start(strings.Join(runSources, ","), fileDest)
+		if err != nil {
+			panic(err)
+		}
+		// the run is saved as a whole, a failed one is kept in the history
+		// with its error and nothing else
+		var saved storage.SaveBatchResult
+		var completions storage.CompletionsSaveResult
+		err = storageService.InTx(func(tx *storage.Storage) error {
+			var err error
+			saved, err = tx.TimersRepo.SaveBatchInRun(runId, timers)
+			if err != nil {
+				return err
+			}
+			if len(batch.completions) > 0 {
+				completions, err = tx.CompletionsRepo.SaveBatchInRun(runId, batch.completions)
+				if err != nil {
+					return err
+				}
+			}
+			if len(deletions) > 0 {
+				ids := make([]int, 0, len(deletions))
+				for _, deletion := range deletions {
+					ids = append(ids, *deletion.Before.Id)
+				}
+				if err := tx.TimersRepo.DeleteManyInRun(runId, ids, !importHardDelete); err != nil {
+					return err
+				}
+			}
+			return tx.ImportRunsRepo.Finish(runId, saved, len(deletions), nil)
+		})
+		if err != nil {
+			if finishErr := storageService.ImportRunsRepo.Finish(runId, storage.SaveBatchResult{}, 0, err); finishErr != nil {
+				fmt.Println("finish import run:", finishErr)
+			}
+			panic(err)
+		}
 
-			fmt.Printf("Imported timer with id %d\n", id)
+		fmt.Printf("Saved timers: %d inserted, %d updated, %d unchanged\n", saved.Inserted, saved.Updated, saved.Unchanged)
+		if len(batch.completions) > 0 {
+			fmt.Printf("Saved completed tasks: %d new\n", completions.New)
+		}
+		if len(deletions) > 0 {
+			fmt.Printf("Deleted %d timers missing from the source\n", len(deletions))
 		}
+		fmt.Printf("Import run %d, revert it with: gomificator import undo %d\n", runId, runId)
+
+		touched, err := storageService.ImportRunsRepo.Days(runId)
+		if err != nil {
+			panic(err)
+		}
+		settleTouchedDays(os.Stdout, cfg, storageService, mergeDays(touched, completions.Days))
 		fmt.Println("done")
 	},
 }
 
+// importBatch holds the timers read from one or more inputs of a single source.
+type importBatch struct {
+	timers      []models.TimerModel
+	completions []models.TaskCompletionModel
+	// detected is the importer picked for a single input by --source auto.
+	detected string
+	// prefixes are the external id prefixes the inputs are authoritative for,
+	// authoritative is false if any input can't be reconciled.
+	prefixes      []string
+	authoritative bool
+	// ranges limit the reconciliation of a prefix to the days its inputs
+	// cover, prefixes in whole are reconciled on every day.
+	ranges map[string][]imprt.DayRange
+	whole  map[string]bool
+}
+
+func (b *importBatch) merge(other importBatch, first bool) {
+	b.timers = append(b.timers, other.timers...)
+	b.completions = append(b.completions, other.completions...)
+	b.authoritative = other.authoritative && (first || b.authoritative)
+	for _, prefix := range other.prefixes {
+		if !slices.Contains(b.prefixes, prefix) {
+			b.prefixes = append(b.prefixes, prefix)
+		}
+	}
+	if b.ranges == nil {
+		b.ranges = make(map[string][]imprt.DayRange)
+		b.whole = make(map[string]bool)
+	}
+	for prefix, ranges := range other.ranges {
+		b.ranges[prefix] = append(b.ranges[prefix], ranges...)
+	}
+	for prefix := range other.whole {
+		b.whole[prefix] = true
+	}
+}
+
+// reconciles tells whether the inputs of the batch cover the day of a stored
+// timer under prefix.
+func (b *importBatch) reconciles(prefix string, timer models.TimerModel) bool {
+	if b.whole[prefix] {
+		return true
+	}
+	return slices.ContainsFunc(b.ranges[prefix], func(r imprt.DayRange) bool { return r.Contains(timer.FixatedAt) })
+}
+
+// collectImportInputs expands a directory into the files below it the
+// importer reads. Hidden directories like .git are skipped. A directory the
+// importer takes as a whole, e.g. a git repository, is kept as it is.
+func collectImportInputs(path string, importerName string) ([]string, error) {
+	if path == imprt.StdinPath {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	if !info.IsDir() || readsWholeDirectory(importerName, path) {
+		return []string{path}, nil
+	}
+
+	var inputs []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != path && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() && imprt.MatchesExtensions(importerName, p) {
+			inputs = append(inputs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", path, err)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no files to import with %s in %s", importerName, path)
+	}
+	return inputs, nil
+}
+
+// readsWholeDirectory tells whether dir is imported as a whole, --source auto
+// takes git repositories that way.
+func readsWholeDirectory(importerName string, dir string) bool {
+	if importerName == imprt.SourceAuto {
+		_, err := os.Stat(filepath.Join(dir, ".git"))
+		return err == nil
+	}
+	return imprt.ReadsDirectories(importerName)
+}
+
+func importInput(importerName string, path string, cfg settings.ImportersConfig) (importBatch, error) {
+	if path != imprt.StdinPath {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return importDirectory(importerName, path, cfg)
+		}
+	}
+
+	input, err := imprt.OpenInput(path)
+	if err != nil {
+		return importBatch{}, fmt.Errorf("open input: %w", err)
+	}
+	defer input.Close()
+
+	var reader io.Reader = input
+	var detected string
+	if importerName == imprt.SourceAuto {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return importBatch{}, fmt.Errorf("read input: %w", err)
+		}
+		importerName, err = imprt.Detect(data)
+		if err != nil {
+			return importBatch{}, fmt.Errorf("%s: %w", path, err)
+		}
+		detected = importerName
+		reader = bytes.NewReader(data)
+	}
+
+	importer, err := imprt.NewImporter(importerName, reader, path, cfg)
+	if err != nil {
+		return importBatch{}, fmt.Errorf("new importer: %w", err)
+	}
+	return runImporter(importer, path, detected)
+}
+
+// importDirectory imports a directory taken as a whole, --source auto only
+// knows git repositories.
+func importDirectory(importerName string, dir string, cfg settings.ImportersConfig) (importBatch, error) {
+	var detected string
+	if importerName == imprt.SourceAuto {
+		if !readsWholeDirectory(importerName, dir) {
+			return importBatch{}, fmt.Errorf("%s: detect source: not a git repository", dir)
+		}
+		importerName = imprt.SourceGit
+		detected = importerName
+	}
+
+	importer, err := imprt.NewDirectoryImporter(importerName, dir, cfg)
+	if err != nil {
+		return importBatch{}, fmt.Errorf("new importer: %w", err)
+	}
+	return runImporter(importer, dir, detected)
+}
+
+// runImporter reads the timers, completions and reconcile prefixes of an importer.
+func runImporter(importer imprt.Importer, path string, detected string) (importBatch, error) {
+	timers, err := importer.Import()
+	if err != nil {
+		return importBatch{}, fmt.Errorf("import %s: %w", path, err)
+	}
+
+	batch := importBatch{timers: timers, detected: detected}
+	if completions, ok := importer.(imprt.CompletionsImporter); ok {
+		batch.completions = completions.Completions()
+	}
+	if authoritative, ok := importer.(imprt.Authoritative); ok {
+		batch.authoritative = true
+		batch.prefixes = authoritative.ExternalIdPrefixes()
+		batch.ranges = make(map[string][]imprt.DayRange)
+		batch.whole = make(map[string]bool)
+		ranged, isRanged := importer.(imprt.DayRanged)
+		for _, prefix := range batch.prefixes {
+			if !isRanged {
+				batch.whole[prefix] = true
+			} else if days, ok := ranged.DayRange(); ok {
+				batch.ranges[prefix] = append(batch.ranges[prefix], days)
+			}
+		}
+	}
+	return batch, nil
+}
+
+// reconcileImportedTimers finds stored timers of the batch's sources that are
+// no longer present in the imported data.
+func reconcileImportedTimers(strg *storage.Storage, batch importBatch) ([]imprt.TimerChange, error) {
+	if !batch.authoritative {
+		return nil, fmt.Errorf("reconcile: source can't be treated as authoritative")
+	}
+
+	var deletions []imprt.TimerChange
+	for _, prefix := range batch.prefixes {
+		hasImported := slices.ContainsFunc(batch.timers, func(t models.TimerModel) bool {
+			return t.ExternalId != nil && strings.HasPrefix(*t.ExternalId, prefix)
+		})
+		if !hasImported {
+			// an empty or broken file must not wipe the whole source
+			fmt.Printf("Skipping reconciliation of %s: nothing was imported for it\n", prefix)
+			continue
+		}
+
+		stored, err := strg.TimersRepo.GetByExternalIdPrefix(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("get timers by prefix %s: %w", prefix, err)
+		}
+		// partial exports only speak for the days they cover
+		stored = slices.DeleteFunc(stored, func(t models.TimerModel) bool { return !batch.reconciles(prefix, t) })
+		deletions = append(deletions, imprt.MissingTimers(stored, batch.timers)...)
+	}
+	return deletions, nil
+}
+
+// affectedDates returns the days of deleted timers, in order.
+func affectedDates(deletions []imprt.TimerChange) []time.Time {
+	var days []time.Time
+	for _, deletion := range deletions {
+		day := deletion.Before.FixatedAt
+		if !slices.ContainsFunc(days, day.Equal) {
+			days = append(days, day)
+		}
+	}
+	slices.SortFunc(days, time.Time.Compare)
+	return days
+}
+
+// printAffectedDates lists the days whose rewards have to be recalculated,
+// queued tells whether fix-rewards --pending already knows about them.
+func printAffectedDates(out io.Writer, days []time.Time, queued bool) {
+	if len(days) == 0 {
+		return
+	}
+
+	dates := make([]string, 0, len(days))
+	for _, day := range days {
+		dates = append(dates, day.Format(constnats.DateLayout))
+	}
+
+	fmt.Fprintf(out, "Rewards need recalculation for: %s\n", strings.Join(dates, ", "))
+	if queued {
+		fmt.Fprintln(out, "Run: gomificator fix-rewards --pending")
+	} else {
+		fmt.Fprintf(out, "Run: gomificator fix-rewards --from %s --to %s\n", dates[0], dates[len(dates)-1])
+	}
+}
+
+func diffImportedTimers(strg *storage.Storage, timers []models.TimerModel) ([]imprt.TimerChange, error) {
+	externalIds := make([]string, 0, len(timers))
+	for _, timer := range timers {
+		if timer.ExternalId != nil {
+			externalIds = append(externalIds, *timer.ExternalId)
+		}
+	}
+
+	existing, err := strg.TimersRepo.GetByExternalIds(externalIds)
+	if err != nil {
+		return nil, fmt.Errorf("get timers by external ids: %w", err)
+	}
+	return imprt.DiffTimers(existing, timers), nil
+}
+
+func printImportDiff(out io.Writer, changes []imprt.TimerChange, details bool) {
+	counts := make(map[imprt.ChangeKind]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+	fmt.Fprintf(out, "Dry run: %d new, %d changed, %d unchanged, %d deleted timers\n",
+		counts[imprt.ChangeInsert], counts[imprt.ChangeUpdate], counts[imprt.ChangeNoop], counts[imprt.ChangeDelete])
+
+	if !details || len(changes) == 0 {
+		return
+	}
+
+	// deletions have no After, describe them by the stored timer
+	shown := func(change imprt.TimerChange) models.TimerModel {
+		if change.Kind == imprt.ChangeDelete {
+			return *change.Before
+		}
+		return change.After
+	}
+
+	slices.SortStableFunc(changes, func(a, b imprt.TimerChange) int {
+		if a.Kind != b.Kind {
+			return int(a.Kind) - int(b.Kind)
+		}
+		return shown(a).FixatedAt.Compare(shown(b).FixatedAt)
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nCHANGE\tDATE\tNAME\tBEFORE, S\tAFTER, S")
+	for _, change := range changes {
+		before, after := "-", "-"
+		if change.Before != nil {
+			before = fmt.Sprintf("%d", int(change.Before.SecondsSpent.Seconds()))
+		}
+		if change.Kind != imprt.ChangeDelete {
+			after = fmt.Sprintf("%d", int(change.After.SecondsSpent.Seconds()))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			change.Kind,
+			shown(change).FixatedAt.Format(constnats.DateLayout),
+			shown(change).Name,
+			before,
+			after,
+		)
+	}
+	w.Flush()
+}
+
+// importerNames lists the values accepted by the --source flags.
+func importerNames() []string {
+	return append([]string{imprt.SourceAuto}, imprt.Names()...)
+}
+
+func completeImporterNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return importerNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
 
-	importCmd.Flags().StringP(FileDestFlag, "F", "", "Path to source file for import")
+	importCmd.Flags().StringP(FileDestFlag, "F", "", `Path to source file or directory for import ("-" reads stdin, .gz and .zip are unpacked, git takes a repository directory)`)
 	importCmd.MarkFlagRequired(FileDestFlag)
 
-	importCmd.Flags().StringP(SourceTypeFlag, "S", imprt.ImporterTypeSuperProductivityExport.String(), "Type of source file")
+	importCmd.Flags().StringP(SourceTypeFlag, "S", imprt.SourceAuto,
+		fmt.Sprintf("Type of source file (%s)", strings.Join(importerNames(), ", ")))
+	importCmd.RegisterFlagCompletionFunc(SourceTypeFlag, completeImporterNames)
+
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Show what would be imported without writing to the database")
+	importCmd.Flags().BoolVar(&importDetails, "details", false, "With --dry-run, list every new, changed and unchanged timer")
+	importCmd.Flags().BoolVar(&importReconcile, "reconcile", false, "Treat the input as a full backup and delete stored timers of its source that are missing from it")
+	importCmd.Flags().BoolVar(&importHardDelete, "hard-delete", false, "With --reconcile, remove missing timers instead of marking them deleted")
 
 	// importCmd.Flags().StringP(SourceTypeFlag, "S", DefaultSourceType, "Type of source file")
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"gomificator/internal/storage"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var importHistoryLimit int
+
+// importHistoryCmd lists recent import runs
+var importHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List recent import runs",
+	Long:  `Lists import runs, newest first. The ID column is what import undo expects.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		strg, err := storage.NewSqlliteStorage()
+		if err != nil {
+			panic(err)
+		}
+
+		runs, err := strg.ImportRunsRepo.List(importHistoryLimit)
+		if err != nil {
+			panic(err)
+		}
+		if len(runs) == 0 {
+			fmt.Println("No imports yet")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTARTED\tSOURCE\tFILE\tINSERTED\tUPDATED\tUNCHANGED\tDELETED\tSTATUS")
+		for _, run := range runs {
+			status := run.Status
+			if run.Error != "" {
+				status += ": " + run.Error
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+				run.Id,
+				run.StartedAt.Local().Format("2006-01-02 15:04"),
+				run.Source,
+				run.File,
+				run.Inserted,
+				run.Updated,
+				run.Unchanged,
+				run.Deleted,
+				status,
+			)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importHistoryCmd)
+
+	importHistoryCmd.Flags().IntVarP(&importHistoryLimit, "limit", "n", 20, "Number of runs to show")
+}
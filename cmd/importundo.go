@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// importUndoCmd reverts the timers written by one import run
+var importUndoCmd = &cobra.Command{
+	Use:   "undo <run-id>",
+	Short: "Revert an import run",
+	Long: `Restores the previous values of timers updated or deleted by the run and removes the
+timers it inserted. Runs changed again by a later import have to be undone newest first.
+Failed runs save nothing, the ones of older versions may have saved a part and can be undone too.
+Rewards of the affected days are settled again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runId, err := strconv.Atoi(args[0])
+		if err != nil {
+			panic(fmt.Errorf("parse run id: %w", err))
+		}
+
+		strg, err := storage.NewSqlliteStorage()
+		if err != nil {
+			panic(err)
+		}
+
+		cfg, err := settings.LoadConfig(nil)
+		if err != nil {
+			panic(err)
+		}
+
+		days, err := strg.ImportRunsRepo.Undo(runId)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Import run %d undone\n", runId)
+		settleTouchedDays(os.Stdout, cfg, strg, days)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importUndoCmd)
+}
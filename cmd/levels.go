@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/rewards"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// levelsCmd shows the XP earned so far and the days levels were reached
+var levelsCmd = &cobra.Command{
+	Use:   "levels",
+	Short: "Show XP and reached levels",
+	Long: `Shows the XP earned so far and lists the levels of the settings with the XP they need and the day
+each was reached. XP comes from focus minutes, completed tasks, medals and streak days weighed by the
+xp settings, days count once they are settled.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := settings.LoadConfig(nil)
+		if err != nil {
+			panic(err)
+		}
+		strg, err := storage.NewSqlliteStorage()
+		if err != nil {
+			panic(err)
+		}
+
+		xp, err := rewards.NewService(cfg, strg).XP(rewards.Today())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("XP: %d (+%d today)\n", xp.Total, xp.Today)
+		if len(cfg.Levels) == 0 {
+			fmt.Println("No levels configured")
+			return
+		}
+
+		for _, level := range cfg.Levels {
+			marker := " "
+			if level.Lvl == xp.Level.Lvl {
+				marker = ">"
+			}
+			reached := ""
+			idx := slices.IndexFunc(xp.LevelUps, func(l models.LevelUpModel) bool { return l.Level == level.Lvl })
+			if idx >= 0 {
+				reached = ", reached " + xp.LevelUps[idx].Day.Format(constnats.DateLayout)
+			}
+			fmt.Printf("%s %d - %s: %d XP%s\n", marker, level.Lvl, level.Name, level.Threshold, reached)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(levelsCmd)
+}
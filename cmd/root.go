@@ -4,7 +4,13 @@ Copyright © 2025 Nikita Shchekodin kacpercku1971@gmail.com
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"gomificator/internal/rewards"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
 	"os"
+	"slices"
 
 	"github.com/spf13/cobra"
 )
@@ -17,6 +23,54 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		for c := cmd; c != nil; c = c.Parent() {
+			if slices.Contains(noSettlementCommands, c.Name()) {
+				return
+			}
+		}
+		if slices.Contains(readOnlyCommands, cmd.Name()) {
+			return
+		}
+		// dry runs promise to write nothing
+		if dryRun, err := cmd.Flags().GetBool("dry-run"); err == nil && dryRun {
+			return
+		}
+		autoSettle()
+	},
+}
+
+// noSettlementCommands print machine-read output or nothing about the data.
+// fix-rewards settles the days it is asked for itself, settling before it
+// would take its --pending days away.
+var noSettlementCommands = []string{"help", "completion", "fix-rewards", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd}
+
+// readOnlyCommands only show data, they don't write anything themselves and
+// leave the settlement to the next command that does. Their subcommands,
+// e.g. streak buy-freeze, settle as usual.
+var readOnlyCommands = []string{"levels", "statistics", "wallet", "streak", "history", "settings"}
+
+// autoSettle settles the days completed since the last run of a command that
+// changes data. Failures are reported but never stop the command itself.
+func autoSettle() {
+	cfg, err := settings.LoadConfig(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Automatic reward settlement skipped:", err)
+		return
+	}
+	strg, err := storage.NewSqlliteStorage()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Automatic reward settlement skipped:", err)
+		return
+	}
+
+	report, err := rewards.NewService(cfg, strg).SettleCompleted(context.Background(), rewards.Today())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Automatic reward settlement failed:", err)
+		return
+	}
+	printSettlement(os.Stderr, report.Dates(), report.Delta)
+	printLevelUps(os.Stderr, report.LevelUps)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/rewards"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// settleTouchedDays settles the days changed by an import and reports the
+// medals won or lost.
+func settleTouchedDays(out io.Writer, cfg *settings.Config, strg *storage.Storage, days []time.Time) {
+	report, err := rewards.NewService(cfg, strg).SettleImported(context.Background(), days)
+	if err != nil {
+		fmt.Fprintln(out, "Automatic reward settlement failed:", err)
+		printAffectedDates(out, days, true)
+		return
+	}
+	printSettlement(out, report.Dates(), report.Delta)
+	printLevelUps(out, report.LevelUps)
+}
+
+// printSettlement reports medals that changed, quiet days print nothing.
+func printSettlement(out io.Writer, days []time.Time, delta models.WalletModel) {
+	if len(delta) == 0 || len(days) == 0 {
+		return
+	}
+
+	period := days[0].Format(constnats.DateLayout)
+	if len(days) > 1 {
+		period += ".." + days[len(days)-1].Format(constnats.DateLayout)
+	}
+	fmt.Fprintf(out, "Rewards settled for %s: %s\n", period, formatWalletDelta(delta))
+}
+
+// printLevelUps announces the levels reached by a settlement.
+func printLevelUps(out io.Writer, levelUps []models.LevelUpModel) {
+	for _, levelUp := range levelUps {
+		fmt.Fprintf(out, "Level up! Reached level %d - %s on %s\n", levelUp.Level, levelUp.Name, levelUp.Day.Format(constnats.DateLayout))
+	}
+}
+
+// formatWalletDelta lists signed medal counts like "+1 bronze, -2 gold".
+func formatWalletDelta(delta models.WalletModel) string {
+	medals := make([]string, 0, len(delta))
+	for medal, cnt := range delta {
+		medals = append(medals, fmt.Sprintf("%+d %s", cnt, medal))
+	}
+	slices.Sort(medals)
+	return strings.Join(medals, ", ")
+}
+
+// formatWallet lists medal counts like "1 gold, 2 bronze", an empty wallet is "nothing".
+func formatWallet(wallet models.WalletModel) string {
+	if len(wallet) == 0 {
+		return "nothing"
+	}
+	medals := make([]string, 0, len(wallet))
+	for medal, cnt := range wallet {
+		medals = append(medals, fmt.Sprintf("%d %s", cnt, medal))
+	}
+	slices.Sort(medals)
+	return strings.Join(medals, ", ")
+}
+
+// mergeDays returns the distinct days of both lists, in order.
+func mergeDays(a, b []time.Time) []time.Time {
+	days := slices.Clone(a)
+	for _, d := range b {
+		if !slices.ContainsFunc(days, d.Equal) {
+			days = append(days, d)
+		}
+	}
+	slices.SortFunc(days, time.Time.Compare)
+	return days
+}
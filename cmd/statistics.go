@@ -6,6 +6,8 @@ package cmd
 import (
 	"fmt"
 	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/rewards"
 	"gomificator/internal/settings"
 	"gomificator/internal/storage"
 	"os"
@@ -19,6 +21,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	statisticsProject string
+	statisticsTag     string
+)
+
 // statisticsCmd represents the statistics command
 var statisticsCmd = &cobra.Command{
 	Use:   "statistics",
@@ -34,39 +41,115 @@ var statisticsCmd = &cobra.Command{
 			panic(err)
 		}
 
-		currentMinutes, err := currentDayMinutes(strg)
+		filter := timerFilter{project: statisticsProject, tag: statisticsTag}
+
+		statisticsModel, err := buildStatisticsModel(*appSettings, strg, filter)
 		if err != nil {
 			panic(err)
 		}
 
-		// Compute total minutes across all timers (TODO: fix bottleneck)
-		totalMinutes, err := totalMinutes(strg)
-		if err != nil {
-			panic(err)
+		if _, err := tea.NewProgram(statisticsModel).Run(); err != nil {
+			fmt.Println("Oh no, it didn't work:", err)
+			os.Exit(1)
 		}
 
-		// Determine current level from settings Levels slice
-		level := currentLevel(appSettings.Levels, totalMinutes)
-		// Display statistics
+	},
+}
+
+// buildStatisticsModel reads today's and all-time minutes from storage.
+func buildStatisticsModel(cfg settings.Config, strg *storage.Storage, filter timerFilter) (modelStatistics, error) {
+	currentMinutes, err := currentDayMinutes(strg, filter)
+	if err != nil {
+		return modelStatistics{}, fmt.Errorf("current day minutes: %w", err)
+	}
 
-		statisticsModel, err := MakeNewStatisticsModel(*appSettings, currentMinutes, totalMinutes, level)
+	// Compute total minutes across all timers (TODO: fix bottleneck)
+	totalMinutes, err := totalMinutes(strg, filter)
+	if err != nil {
+		return modelStatistics{}, fmt.Errorf("total minutes: %w", err)
+	}
+
+	svc := rewards.NewService(&cfg, strg)
+	xp, err := svc.XP(rewards.Today())
+	if err != nil {
+		return modelStatistics{}, fmt.Errorf("xp: %w", err)
+	}
+
+	model, err := MakeNewStatisticsModel(cfg, currentMinutes, totalMinutes, xp.Level)
+	if err != nil {
+		return model, err
+	}
+	model.xp = xp.Total
+	model.xpToday = xp.Today
+	model.levelStart = xp.Level.Threshold
+	model.nextLevel = xp.Next
+	for _, levelUp := range xp.LevelUps {
+		if levelUp.Level == xp.Level.Lvl {
+			model.levelSince = levelUp.Day
+		}
+	}
+	model.levelProgress = progress.New(progress.WithDefaultGradient())
+	model.levelProgress.Width = maxWidth
+
+	streaks, err := svc.Streaks(rewards.Today())
+	if err != nil {
+		return model, fmt.Errorf("streaks: %w", err)
+	}
+	model.currentStreak = streaks.Current
+	model.bestStreak = streaks.Best
+
+	if len(cfg.WeeklyGoals) > 0 {
+		week, err := svc.PreviewPeriod(rewards.PeriodWeek, rewards.Today())
 		if err != nil {
-			panic(err)
+			return model, fmt.Errorf("preview week: %w", err)
+		}
+		model.weekGoals = periodGoalProgresses(week)
+	}
+	if len(cfg.MonthlyGoals) > 0 {
+		month, err := svc.PreviewPeriod(rewards.PeriodMonth, rewards.Today())
+		if err != nil {
+			return model, fmt.Errorf("preview month: %w", err)
 		}
+		model.monthGoals = periodGoalProgresses(month)
+	}
+	return model, nil
+}
 
-		if _, err := tea.NewProgram(statisticsModel).Run(); err != nil {
-			fmt.Println("Oh no, it didn't work:", err)
-			os.Exit(1)
+// periodGoalProgresses shows a progress bar for every target of the weekly or monthly goals.
+func periodGoalProgresses(period rewards.PeriodResult) []GoalProgressModel {
+	var progresses []GoalProgressModel
+	for _, goal := range period.Goals {
+		if goal.Minutes > 0 {
+			progresses = append(progresses, MakePeriodGoalProgress(goal.Minutes, period.Minutes, goal.Count, goal.Medal, "Minutes"))
+		}
+		if goal.Days > 0 {
+			progresses = append(progresses, MakePeriodGoalProgress(goal.Days, period.GoalDays, goal.Count, goal.Medal, "Days with a goal met"))
 		}
+	}
+	return progresses
+}
 
-	},
+// timerFilter keeps timers of a project and tag, matched by title; empty fields match everything.
+type timerFilter struct {
+	project string
+	tag     string
 }
 
-func currentDayMinutes(strg *storage.Storage) (int, error) {
-	return getDayMinutes(strg, time.Now())
+func (f timerFilter) matches(t models.TimerModel) bool {
+	if f.project != "" && (t.Project == nil || !strings.EqualFold(t.Project.Title, f.project)) {
+		return false
+	}
+	if f.tag != "" && !slices.ContainsFunc(t.Tags, func(tag models.TagModel) bool { return strings.EqualFold(tag.Title, f.tag) }) {
+		return false
+	}
+	return true
 }
 
-func getDayMinutes(strg *storage.Storage, day time.Time) (int, error) {
+func currentDayMinutes(strg *storage.Storage, filter timerFilter) (int, error) {
+	return getDayMinutes(strg, time.Now(), filter)
+}
+
+func getDayMinutes(strg *storage.Storage, day time.Time, filter timerFilter) (int, error) {
 	validTimers, err := strg.TimersRepo.GetTimersBetweenDates(
 		day, day,
 	)
@@ -77,13 +160,15 @@ func getDayMinutes(strg *storage.Storage, day time.Time) (int, error) {
 	// Calculate total focus time
 	totalDuration := time.Duration(0)
 	for _, timer := range validTimers {
-		totalDuration += timer.SecondsSpent
+		if filter.matches(timer) {
+			totalDuration += timer.SecondsSpent
+		}
 	}
 
 	return int(totalDuration.Minutes()), nil
 }
 
-func totalMinutes(strg *storage.Storage) (int, error) {
+func totalMinutes(strg *storage.Storage, filter timerFilter) (int, error) {
 	allTimers, err := strg.TimersRepo.GetTimersBetweenDates(
 		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		time.Now(),
@@ -93,25 +178,12 @@ func totalMinutes(strg *storage.Storage) (int, error) {
 	}
 	totalDuration := time.Duration(0)
 	for _, t := range allTimers {
-		totalDuration += t.SecondsSpent
-	}
-
-	return int(totalDuration.Minutes()), nil
-}
-
-func currentLevel(levels []settings.LevelDef, totalMinutes int) settings.LevelDef {
-	level := settings.LevelDef{}
-	if len(levels) > 0 {
-		for i := range levels {
-			if totalMinutes >= levels[i].Threshold {
-				level = levels[i]
-			} else {
-				break
-			}
+		if filter.matches(t) {
+			totalDuration += t.SecondsSpent
 		}
 	}
 
-	return level
+	return int(totalDuration.Minutes()), nil
 }
 
 func init() {
@@ -126,6 +198,8 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// statisticsCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	statisticsCmd.Flags().StringVar(&statisticsProject, "project", "", "Count only time of the project with this title")
+	statisticsCmd.Flags().StringVar(&statisticsTag, "tag", "", "Count only time of tasks with this tag")
 }
 
 const (
@@ -148,9 +222,22 @@ type modelStatistics struct {
 	nearestRest   time.Time
 	dayType       string
 	goalProgreses []GoalProgressModel
+	weekGoals     []GoalProgressModel
+	monthGoals    []GoalProgressModel
 	totalMinutes  int
+	currentStreak int
+	bestStreak    int
 	levelNum      int
 	levelName     string
+	// levelSince is the day the level was reached, zero if it isn't recorded.
+	levelSince time.Time
+	xp         int
+	xpToday    int
+	// levelStart is the XP threshold of the current level, nextLevel is nil
+	// on the last one.
+	levelStart    int
+	nextLevel     *settings.LevelDef
+	levelProgress progress.Model
 }
 
 func (m modelStatistics) Init() tea.Cmd {
@@ -176,9 +263,17 @@ func (m modelStatistics) View() string {
 	if m.levelName != "" || m.levelNum > 0 {
 		lvlLine = fmt.Sprintf("%d - %s", m.levelNum, m.levelName)
 	}
+	if !m.levelSince.IsZero() {
+		lvlLine += fmt.Sprintf(" (since %s)", m.levelSince.Format(constnats.DateLayout))
+	}
 	summary := []string{
 		formatKV("Total Minutes", fmt.Sprintf("%d", m.totalMinutes)),
 		formatKV("Current Level", lvlLine),
+		formatKV("XP", m.viewXP()),
+		formatKV("Streak", fmt.Sprintf("%d days (best %d)", m.currentStreak, m.bestStreak)),
+	}
+	if m.nextLevel != nil {
+		summary = append(summary, m.levelProgress.ViewAs(m.levelProgressCoef()))
 	}
 	out += sectionTitleStyle.Render("Summary") + "\n"
 	out += boxStyle.Render(strings.Join(summary, "\n")) + "\n\n"
@@ -204,9 +299,38 @@ func (m modelStatistics) View() string {
 		out += strings.Join(goals, "\n\n")
 	}
 
+	out += viewPeriodGoals("This week", m.weekGoals)
+	out += viewPeriodGoals("This month", m.monthGoals)
+
 	return out + "\n"
 }
 
+// viewXP is like "1250 (+40 today), 250 to level 3 - Expert".
+func (m modelStatistics) viewXP() string {
+	out := fmt.Sprintf("%d (+%d today)", m.xp, m.xpToday)
+	if m.nextLevel == nil {
+		return out + ", last level reached"
+	}
+	return out + fmt.Sprintf(", %d to level %d - %s", m.nextLevel.Threshold-m.xp, m.nextLevel.Lvl, m.nextLevel.Name)
+}
+
+// levelProgressCoef is the part of the XP between the current and the next level earned so far.
+func (m modelStatistics) levelProgressCoef() float64 {
+	return float64(m.xp-m.levelStart) / float64(m.nextLevel.Threshold-m.levelStart)
+}
+
+// viewPeriodGoals renders a section of weekly or monthly goals, nothing if there are none.
+func viewPeriodGoals(title string, progresses []GoalProgressModel) string {
+	if len(progresses) == 0 {
+		return ""
+	}
+	var goals []string
+	for _, goalProgress := range progresses {
+		goals = append(goals, goalProgress.Show())
+	}
+	return "\n\n" + sectionTitleStyle.Render(title) + "\n" + strings.Join(goals, "\n\n")
+}
+
 func (m modelStatistics) viewRestStatusBlock() string {
 	out := "Rest status: "
 
@@ -280,11 +404,10 @@ func calculateNearestRestTime(restBottomLine time.Time, goals []GoalProgressMode
 		nextGoal := goals[nearestRestId-1]
 		reachedGoal := goals[nearestRestId]
 		timeDiff := reachedGoal.restAfter.Sub(nextGoal.restAfter)
-		scoreDiff := nextGoal.targetMinutes - reachedGoal.targetMinutes
-		minutesAboveReachedGoal := currentMinutes - reachedGoal.targetMinutes
+		scoreDiff := nextGoal.target - reachedGoal.target
+		minutesAboveReachedGoal := currentMinutes - reachedGoal.target
 		coef := float64(minutesAboveReachedGoal) / float64(scoreDiff)
 		timeDiff = time.Duration(float64(timeDiff) * coef)
-		fmt.Println(timeDiff, scoreDiff, minutesAboveReachedGoal, coef)
 
 		nearestRestTime = nearestRestTime.Add(-timeDiff)
 	}
@@ -293,12 +416,21 @@ func calculateNearestRestTime(restBottomLine time.Time, goals []GoalProgressMode
 }
 
 type GoalProgressModel struct {
-	targetMinutes  int
-	currentMinutes int
-	medalCount     int
-	medalType      constnats.Medal
-	restAfter      time.Time
-	Progress       progress.Model
+	target     int
+	current    int
+	unit       string
+	medalCount int
+	medalType  constnats.Medal
+	// restAfter is zero for goals without a rest time, e.g. weekly ones.
+	restAfter time.Time
+	Progress  progress.Model
+}
+
+// MakePeriodGoalProgress shows the progress of a weekly or monthly goal, unit names what is counted.
+func MakePeriodGoalProgress(target, current, medalCount int, medalType constnats.Medal, unit string) GoalProgressModel {
+	g := MakeGoalProgress(target, current, medalCount, medalType, time.Time{})
+	g.unit = unit
+	return g
 }
 
 func MakeGoalProgress(targetMinutes, currentMinutes, medalCount int, medalType constnats.Medal, restAfter time.Time) GoalProgressModel {
@@ -322,23 +454,27 @@ func MakeGoalProgress(targetMinutes, currentMinutes, medalCount int, medalType c
 	progressBar.Width = maxWidth
 
 	return GoalProgressModel{
-		targetMinutes:  targetMinutes,
-		currentMinutes: currentMinutes,
-		medalCount:     medalCount,
-		medalType:      medalType,
-		Progress:       progressBar,
-		restAfter:      restAfter,
+		target:     targetMinutes,
+		current:    currentMinutes,
+		unit:       "Minutes",
+		medalCount: medalCount,
+		medalType:  medalType,
+		Progress:   progressBar,
+		restAfter:  restAfter,
 	}
 }
 
 func (g GoalProgressModel) Show() string {
+	reward := fmt.Sprintf("Medal - %d %s", g.medalCount, g.medalType)
+	if !g.restAfter.IsZero() {
+		reward = fmt.Sprintf("Rest from %s, %s", g.restAfter.Format(constnats.TimeLayout), reward)
+	}
 
-	out := fmt.Sprintf("%d/%d Minutes\nRewards: Rest from %s, Medal - %d %s\n%s",
-		g.currentMinutes,
-		g.targetMinutes,
-		g.restAfter.Format(constnats.TimeLayout),
-		g.medalCount,
-		g.medalType,
+	out := fmt.Sprintf("%d/%d %s\nRewards: %s\n%s",
+		g.current,
+		g.target,
+		g.unit,
+		reward,
 		g.Progress.ViewAs(g.getProgressCoef()),
 	)
 	if g.getProgressCoef() >= 1 {
@@ -348,5 +484,5 @@ func (g GoalProgressModel) Show() string {
 }
 
 func (g GoalProgressModel) getProgressCoef() float64 {
-	return float64(g.currentMinutes) / float64(g.targetMinutes)
+	return float64(g.current) / float64(g.target)
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"gomificator/internal/rewards"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// streakCmd shows the streaks of days with a met daily goal
+var streakCmd = &cobra.Command{
+	Use:   "streak",
+	Short: "Show current and best streak",
+	Long: `Shows the number of consecutive days on which at least one daily goal was met. Days whose day type
+has no goals don't break a streak, a streak freeze keeps it over one missed day.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := settings.LoadConfig(nil)
+		if err != nil {
+			panic(err)
+		}
+		strg, err := storage.NewSqlliteStorage()
+		if err != nil {
+			panic(err)
+		}
+
+		streaks, err := rewards.NewService(cfg, strg).Streaks(rewards.Today())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Current streak: %d days\n", streaks.Current)
+		fmt.Printf("Best streak: %d days\n", streaks.Best)
+		fmt.Printf("Streak freezes: %d\n", streaks.Freezes)
+	},
+}
+
+// streakBuyFreezeCmd buys a streak freeze with medals
+var streakBuyFreezeCmd = &cobra.Command{
+	Use:   "buy-freeze",
+	Short: "Buy a streak freeze",
+	Long:  `Pays streaks.freezeprice from the wallet for a freeze that is used up by the next missed day of a running streak.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := settings.LoadConfig(nil)
+		if err != nil {
+			panic(err)
+		}
+		strg, err := storage.NewSqlliteStorage()
+		if err != nil {
+			panic(err)
+		}
+
+		freezes, err := rewards.NewService(cfg, strg).BuyStreakFreeze()
+		if err != nil {
+			// a blocked shop or a short wallet is not a crash
+			fmt.Fprintln(os.Stderr, "Can't buy a streak freeze:", err)
+			os.Exit(1)
+		}
+		price := cfg.Streaks.FreezePrice
+		fmt.Printf("Bought a streak freeze for %d %s, you have %d\n", price.Count, price.Medal, freezes)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(streakCmd)
+	streakCmd.AddCommand(streakBuyFreezeCmd)
+}
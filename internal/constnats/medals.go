@@ -2,6 +2,7 @@ package constnats
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -23,6 +24,14 @@ var medalByString = map[string]Medal{
 	"gold":   MedalGold,
 }
 
+// medalsByValue lists the medals from the least to the most valuable.
+var medalsByValue = []Medal{MedalWood, MedalSteel, MedalBronze, MedalSilver, MedalGold}
+
+// Rank orders medals by value, wood is the lowest with 0.
+func (m Medal) Rank() int {
+	return slices.Index(medalsByValue, m)
+}
+
 // LoadMedal loads the medal value from its string representation.
 func LoadMedal(s string) (Medal, error) {
 	medal, ok := medalByString[strings.ToLower(s)]
@@ -0,0 +1,144 @@
+package imprt
+
+import (
+	"encoding/json"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const activityWatch = "ActivityWatch"
+
+func init() {
+	Register(SourceActivityWatch, func(r io.Reader, _ string, cfg settings.ImportersConfig) Importer {
+		return NewImporterFromActivityWatchExportFile(r, cfg.ActivityWatch)
+	}, ".json")
+}
+
+// activityWatchWindowBucket is the bucket type written by aw-watcher-window.
+const activityWatchWindowBucket = "currentwindow"
+
+type activityWatchExport struct {
+	Buckets map[string]activityWatchBucket `json:"buckets"`
+}
+
+type activityWatchBucket struct {
+	Id     string               `json:"id"`
+	Type   string               `json:"type"`
+	Events []activityWatchEvent `json:"events"`
+}
+
+type activityWatchEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Duration  float64   `json:"duration"`
+	Data      struct {
+		App   string `json:"app"`
+		Title string `json:"title"`
+	} `json:"data"`
+}
+
+type importerActivityWatchExportFile struct {
+	exportFile io.Reader
+	cfg        settings.ActivityWatchImportConfig
+	days       DayRange
+}
+
+// NewImporterFromActivityWatchExportFile creates an importer for an ActivityWatch
+// bucket export. Only window events that match cfg are imported, summed per app and day.
+func NewImporterFromActivityWatchExportFile(file io.Reader, cfg settings.ActivityWatchImportConfig) Importer {
+	return &importerActivityWatchExportFile{
+		exportFile: file,
+		cfg:        cfg,
+	}
+}
+
+func (i *importerActivityWatchExportFile) Import() ([]models.TimerModel, error) {
+	if len(i.cfg.Apps) == 0 && len(i.cfg.TitlesRe) == 0 {
+		return nil, fmt.Errorf("no apps or titles configured in importers.activitywatch settings")
+	}
+
+	var export activityWatchExport
+	if err := json.NewDecoder(i.exportFile).Decode(&export); err != nil {
+		return nil, fmt.Errorf("decode export file: %w", err)
+	}
+
+	type appDay struct {
+		app string
+		day time.Time
+	}
+	totals := make(map[appDay]time.Duration)
+	appNames := make(map[string]string)
+
+	i.days = DayRange{}
+	for _, bucket := range export.Buckets {
+		if bucket.Type != activityWatchWindowBucket {
+			continue
+		}
+		for _, event := range bucket.Events {
+			start := event.Timestamp.Local()
+			end := start.Add(time.Duration(event.Duration * float64(time.Second)))
+			spans := splitByDay(start, end)
+			// days without matching events are covered by the export too
+			for _, span := range spans {
+				i.days = i.days.extend(span.Day)
+			}
+
+			if !i.matches(event) {
+				continue
+			}
+			app := strings.ToLower(event.Data.App)
+			if _, ok := appNames[app]; !ok {
+				appNames[app] = event.Data.App
+			}
+			for _, span := range spans {
+				totals[appDay{app: app, day: span.Day}] += span.End.Sub(span.Start)
+			}
+		}
+	}
+
+	timers := make([]models.TimerModel, 0, len(totals))
+	for key, spent := range totals {
+		externalId := generateActivityWatchExternalId(key.app, key.day)
+		timers = append(timers, models.TimerModel{
+			Id:           nil,
+			ExternalId:   &externalId,
+			CreatedAt:    nil,
+			Name:         appNames[key.app],
+			Description:  "",
+			SecondsSpent: spent.Round(time.Second),
+			FixatedAt:    key.day,
+		})
+	}
+
+	return timers, nil
+}
+
+func (i *importerActivityWatchExportFile) ExternalIdPrefixes() []string {
+	return []string{activityWatch + ":"}
+}
+
+// DayRange is the range of days with window events in the export.
+func (i *importerActivityWatchExportFile) DayRange() (DayRange, bool) {
+	return i.days, !i.days.From.IsZero()
+}
+
+func (i *importerActivityWatchExportFile) matches(event activityWatchEvent) bool {
+	if slices.ContainsFunc(i.cfg.Apps, func(app string) bool { return strings.EqualFold(app, event.Data.App) }) {
+		return true
+	}
+	for _, re := range i.cfg.TitlesRe {
+		if re.MatchString(event.Data.Title) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateActivityWatchExternalId(app string, day time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", activityWatch, app, day.Format(constnats.DateLayout))
+}
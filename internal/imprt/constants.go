@@ -1,33 +1,14 @@
 package imprt
 
-import "fmt"
-
-type ImporterType int
-
+// Names of the importers shipped with gomificator.
 const (
-	ImporterTypeSuperProductivityExport ImporterType = iota
-	ImporterTypeSuperProductivityBackup
+	SourceSuperProductivityExport = "spexport"
+	SourceSuperProductivityBackup = "spbackup"
+	SourceOrg                     = "org"
+	SourceActivityWatch           = "activitywatch"
+	SourceWakaTime                = "wakatime"
+	// SourceGit reads a repository directory, SourceGitList a file listing
+	// repository paths.
+	SourceGit     = "git"
+	SourceGitList = "gitlist"
 )
-
-var importerTypeMap = map[ImporterType]string{
-	ImporterTypeSuperProductivityExport: "spexport",
-	ImporterTypeSuperProductivityBackup: "spbackup",
-}
-
-func (i ImporterType) String() string {
-	return importerTypeMap[i]
-}
-
-// ImporterTypeSuperProductivityExport ImporterType = "spexport"
-// ImporterTypeSuperProductivityBackup ImporterType = "spbackup"
-
-func GetImporterTypeByString(importerTypeStr string) (ImporterType, error) {
-	switch importerTypeStr {
-	case importerTypeMap[ImporterTypeSuperProductivityExport]:
-		return ImporterTypeSuperProductivityExport, nil
-	case importerTypeMap[ImporterTypeSuperProductivityBackup]:
-		return ImporterTypeSuperProductivityBackup, nil
-	default:
-		return 0, fmt.Errorf("unknown importer type: %s", importerTypeStr)
-	}
-}
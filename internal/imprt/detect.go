@@ -0,0 +1,116 @@
+package imprt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SourceAuto asks Detect to pick the importer by looking at the input.
+const SourceAuto = "auto"
+
+// Formats that can be recognized but have no importer shipped with gomificator.
+const (
+	formatCSV       = "csv"
+	formatICalendar = "ics"
+)
+
+// orgKeywordRe matches in-buffer settings like #+TITLE:, which markdown doesn't
+// have. Headings alone look like markdown bullet lists.
+var orgKeywordRe = regexp.MustCompile(`^\s*#\+[A-Za-z_]+`)
+
+// jsonKeySources maps a top-level JSON key to the source that owns it.
+// The SP export wraps everything in "data", while the backup starts with "task".
+var jsonKeySources = []struct {
+	key    string
+	source string
+}{
+	{key: "data", source: SourceSuperProductivityExport},
+	{key: "task", source: SourceSuperProductivityBackup},
+	{key: "buckets", source: SourceActivityWatch},
+	{key: "days", source: SourceWakaTime},
+}
+
+// Detect sniffs data and returns the name of the registered importer for it.
+func Detect(data []byte) (string, error) {
+	format, err := detectFormat(data)
+	if err != nil {
+		return "", err
+	}
+
+	registryMu.RLock()
+	_, ok := registry[format]
+	registryMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("detected %s input, but no importer is registered for it", format)
+	}
+	return format, nil
+}
+
+func detectFormat(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return "", fmt.Errorf("detect source: input is empty")
+	}
+
+	if trimmed[0] == '{' {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return "", fmt.Errorf("detect source: decode json: %w", err)
+		}
+		for _, candidate := range jsonKeySources {
+			if _, ok := doc[candidate.key]; ok {
+				return candidate.source, nil
+			}
+		}
+		return "", fmt.Errorf("detect source: unrecognized JSON document")
+	}
+
+	if bytes.HasPrefix(trimmed, []byte("BEGIN:VCALENDAR")) {
+		return formatICalendar, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	firstLine := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if firstLine == "" {
+			firstLine = line
+		}
+		if orgKeywordRe.MatchString(line) || orgClockRe.MatchString(line) {
+			return SourceOrg, nil
+		}
+	}
+
+	if looksLikeCSVHeader(firstLine) {
+		return formatCSV, nil
+	}
+
+	return "", fmt.Errorf("detect source: unknown format, pass the source type explicitly")
+}
+
+func looksLikeCSVHeader(line string) bool {
+	for _, sep := range []string{",", ";", "\t"} {
+		fields := strings.Split(line, sep)
+		if len(fields) < 2 {
+			continue
+		}
+		valid := true
+		for _, field := range fields {
+			field = strings.Trim(strings.TrimSpace(field), `"`)
+			if field == "" || len(field) > 64 {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package imprt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{name: "sp export", data: `{"data": {"task": {}}}`, want: SourceSuperProductivityExport},
+		{name: "sp backup", data: `{"task": {}, "project": {}}`, want: SourceSuperProductivityBackup},
+		{name: "activitywatch", data: `{"buckets": {}}`, want: SourceActivityWatch},
+		{name: "wakatime", data: `{"user": {}, "days": []}`, want: SourceWakaTime},
+		{name: "byte order mark", data: "\xef\xbb\xbf" + `{"days": []}`, want: SourceWakaTime},
+		{name: "org keywords", data: "#+TITLE: notes\n* Project\n", want: SourceOrg},
+		{name: "org keywords after a heading", data: "* Project\n  #+begin_src go\n  #+end_src\n", want: SourceOrg},
+		{name: "org clock only", data: "  CLOCK: [2025-11-03 Mon 09:00]--[2025-11-03 Mon 10:00] =>  1:00\n", want: SourceOrg},
+		{name: "empty", data: " \n\t", wantErr: "input is empty"},
+		{name: "broken json", data: `{"days": `, wantErr: "decode json"},
+		{name: "unknown json", data: `{"foo": 1}`, wantErr: "unrecognized JSON document"},
+		{name: "csv without importer", data: "date,project,seconds\n2025-11-03,p,60\n", wantErr: "no importer is registered"},
+		{name: "icalendar without importer", data: "BEGIN:VCALENDAR\nEND:VCALENDAR\n", wantErr: "no importer is registered"},
+		{name: "plain text", data: "just some notes\n", wantErr: "unknown format"},
+		{name: "markdown bullet list", data: "# Notes\n\n* first item\n* second item\n** nested item\n", wantErr: "unknown format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Detect() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
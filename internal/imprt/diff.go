@@ -0,0 +1,124 @@
+package imprt
+
+import (
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ChangeKind int
+
+const (
+	ChangeInsert ChangeKind = iota
+	ChangeUpdate
+	ChangeNoop
+	ChangeDelete
+)
+
+var changeKindMap = map[ChangeKind]string{
+	ChangeInsert: "new",
+	ChangeUpdate: "changed",
+	ChangeNoop:   "unchanged",
+	ChangeDelete: "deleted",
+}
+
+func (c ChangeKind) String() string {
+	return changeKindMap[c]
+}
+
+// TimerChange describes what saving an imported timer would do to the stored one.
+// After is empty for ChangeDelete.
+type TimerChange struct {
+	Kind   ChangeKind
+	Before *models.TimerModel
+	After  models.TimerModel
+}
+
+// DiffTimers compares imported timers with the stored ones, keyed by external id.
+func DiffTimers(existing map[string]models.TimerModel, incoming []models.TimerModel) []TimerChange {
+	changes := make([]TimerChange, 0, len(incoming))
+	for _, timer := range incoming {
+		if timer.ExternalId == nil {
+			changes = append(changes, TimerChange{Kind: ChangeInsert, After: timer})
+			continue
+		}
+
+		stored, ok := existing[*timer.ExternalId]
+		if !ok {
+			changes = append(changes, TimerChange{Kind: ChangeInsert, After: timer})
+			continue
+		}
+
+		kind := ChangeUpdate
+		if stored.DeletedAt == nil && timersEqual(stored, timer) {
+			kind = ChangeNoop
+		}
+		changes = append(changes, TimerChange{Kind: kind, Before: &stored, After: timer})
+	}
+	return changes
+}
+
+// timersEqual compares timers the way they are persisted: whole seconds and dates only.
+func timersEqual(a, b models.TimerModel) bool {
+	return a.Name == b.Name &&
+		ptrValue(a.ParentExternalId) == ptrValue(b.ParentExternalId) &&
+		projectKey(a.Project) == projectKey(b.Project) &&
+		(b.Tags == nil || tagsKey(a.Tags) == tagsKey(b.Tags)) &&
+		a.Description == b.Description &&
+		a.FixatedAt.Format(constnats.DateLayout) == b.FixatedAt.Format(constnats.DateLayout) &&
+		a.SecondsSpent.Truncate(time.Second) == b.SecondsSpent.Truncate(time.Second) &&
+		endedAtEqual(a.EndedAt, b.EndedAt)
+}
+
+func endedAtEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
+
+// MissingTimers returns deletions for stored timers that are absent from incoming.
+func MissingTimers(stored []models.TimerModel, incoming []models.TimerModel) []TimerChange {
+	seen := make(map[string]struct{}, len(incoming))
+	for _, timer := range incoming {
+		if timer.ExternalId != nil {
+			seen[*timer.ExternalId] = struct{}{}
+		}
+	}
+
+	var changes []TimerChange
+	for _, timer := range stored {
+		if timer.ExternalId == nil {
+			continue
+		}
+		if _, ok := seen[*timer.ExternalId]; !ok {
+			changes = append(changes, TimerChange{Kind: ChangeDelete, Before: &timer})
+		}
+	}
+	return changes
+}
+
+func ptrValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func projectKey(p *models.ProjectModel) string {
+	if p == nil {
+		return ""
+	}
+	return p.ExternalId + "\x00" + p.Title
+}
+
+func tagsKey(tags []models.TagModel) string {
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, tag.ExternalId+"\x00"+tag.Title)
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, "\x01")
+}
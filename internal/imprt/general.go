@@ -1,7 +1,76 @@
 package imprt
 
-import "gomificator/internal/models"
+import (
+	"gomificator/internal/models"
+	"time"
+)
 
 type Importer interface {
 	Import() ([]models.TimerModel, error)
 }
+
+// Authoritative is implemented by importers whose input is a complete copy of
+// the source. Stored timers under the returned external id prefixes that are
+// missing from the input were deleted in the source. Call it after Import.
+type Authoritative interface {
+	ExternalIdPrefixes() []string
+}
+
+// DayRanged is implemented by authoritative importers whose input may cover
+// only some days of the source, e.g. an export of the last week. Stored timers
+// of other days are left alone by reconciliation. ok is false if the input
+// covers no day at all. Call it after Import.
+type DayRanged interface {
+	DayRange() (r DayRange, ok bool)
+}
+
+// DayRange is the inclusive range of days an input covers.
+type DayRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains tells whether day is within the range.
+func (r DayRange) Contains(day time.Time) bool {
+	return !day.Before(r.From) && !day.After(r.To)
+}
+
+// extend grows the range to include day, a zero range becomes the day itself.
+func (r DayRange) extend(day time.Time) DayRange {
+	if r.From.IsZero() || day.Before(r.From) {
+		r.From = day
+	}
+	if r.To.IsZero() || day.After(r.To) {
+		r.To = day
+	}
+	return r
+}
+
+// CompletionsImporter is implemented by importers that also know which tasks
+// were done. Completions are available after Import.
+type CompletionsImporter interface {
+	Completions() []models.TaskCompletionModel
+}
+
+// daySpan is a part of a time interval that belongs to a single calendar day.
+type daySpan struct {
+	Day   time.Time
+	Start time.Time
+	End   time.Time
+}
+
+// splitByDay cuts the [start, end) interval at local midnights.
+func splitByDay(start, end time.Time) []daySpan {
+	var spans []daySpan
+	for start.Before(end) {
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		nextMidnight := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, start.Location())
+		spanEnd := end
+		if nextMidnight.Before(end) {
+			spanEnd = nextMidnight
+		}
+		spans = append(spans, daySpan{Day: day, Start: start, End: spanEnd})
+		start = spanEnd
+	}
+	return spans
+}
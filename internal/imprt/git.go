@@ -0,0 +1,211 @@
+package imprt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gitRepository = "Git"
+
+type gitCommit struct {
+	Hash string
+	At   time.Time
+}
+
+func init() {
+	RegisterDirectory(SourceGit, func(dir string, cfg settings.ImportersConfig) Importer {
+		return NewImporterFromGitRepositories([]string{dir}, cfg.Git)
+	})
+	Register(SourceGitList, func(r io.Reader, _ string, cfg settings.ImportersConfig) Importer {
+		return NewImporterFromGitRepositoryList(r, cfg.Git)
+	})
+}
+
+type importerGitRepositoryList struct {
+	listFile io.Reader
+	cfg      settings.GitImportConfig
+	repos    Importer
+}
+
+// NewImporterFromGitRepositoryList creates a git importer for the repositories
+// listed in file, one path per line. Empty lines and lines starting with # are ignored.
+func NewImporterFromGitRepositoryList(file io.Reader, cfg settings.GitImportConfig) Importer {
+	return &importerGitRepositoryList{
+		listFile: file,
+		cfg:      cfg,
+	}
+}
+
+func (i *importerGitRepositoryList) Import() ([]models.TimerModel, error) {
+	var paths []string
+	scanner := bufio.NewScanner(i.listFile)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan repository list: %w", err)
+	}
+
+	i.repos = NewImporterFromGitRepositories(paths, i.cfg)
+	return i.repos.Import()
+}
+
+func (i *importerGitRepositoryList) ExternalIdPrefixes() []string {
+	if i.repos == nil {
+		return nil
+	}
+	return i.repos.(Authoritative).ExternalIdPrefixes()
+}
+
+type importerGitRepositories struct {
+	paths []string
+	cfg   settings.GitImportConfig
+	repos []string
+}
+
+// NewImporterFromGitRepositories creates an importer that estimates coding
+// sessions of local git repositories from their commit timestamps.
+func NewImporterFromGitRepositories(paths []string, cfg settings.GitImportConfig) Importer {
+	return &importerGitRepositories{
+		paths: paths,
+		cfg:   cfg,
+	}
+}
+
+func (i *importerGitRepositories) Import() ([]models.TimerModel, error) {
+	var timers []models.TimerModel
+	for _, path := range i.paths {
+		commits, err := i.readCommits(path)
+		if err != nil {
+			return nil, fmt.Errorf("read commits of %s: %w", path, err)
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("abs path %s: %w", path, err)
+		}
+		i.repos = append(i.repos, absPath)
+		timers = append(timers, createGitSessionTimers(absPath, commits, i.cfg)...)
+	}
+	return timers, nil
+}
+
+// ExternalIdPrefixes limits reconciliation to the repositories that were read.
+func (i *importerGitRepositories) ExternalIdPrefixes() []string {
+	prefixes := make([]string, 0, len(i.repos))
+	for _, repo := range i.repos {
+		prefixes = append(prefixes, gitRepositoryPrefix(repo))
+	}
+	return prefixes
+}
+
+// gitRepositoryPrefix keys the timers of a repository by its absolute path,
+// repositories in directories of the same name don't share them.
+func gitRepositoryPrefix(repo string) string {
+	return fmt.Sprintf("%s:%s:", gitRepository, repo)
+}
+
+func (i *importerGitRepositories) readCommits(path string) ([]gitCommit, error) {
+	args := []string{"-C", path, "log", "--all", "--format=%H%x09%at"}
+	if i.cfg.Author != "" {
+		args = append(args, "--author="+i.cfg.Author)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseGitLog(bytes.NewReader(out))
+}
+
+// parseGitLog reads "<hash>\t<unix timestamp>" lines and returns commits in chronological order.
+func parseGitLog(r io.Reader) ([]gitCommit, error) {
+	var commits []gitCommit
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, tsStr, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected git log line %q", line)
+		}
+		ts, err := strconv.ParseInt(tsStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse timestamp %q: %w", tsStr, err)
+		}
+		commits = append(commits, gitCommit{Hash: hash, At: time.Unix(ts, 0)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	slices.SortFunc(commits, func(a, b gitCommit) int {
+		return a.At.Compare(b.At)
+	})
+	return commits, nil
+}
+
+// createGitSessionTimers groups commits into sessions separated by more than
+// cfg.Gap and turns every session, split at midnight, into timers named after
+// the directory of the repository.
+func createGitSessionTimers(repo string, commits []gitCommit, cfg settings.GitImportConfig) []models.TimerModel {
+	var timers []models.TimerModel
+	name := filepath.Base(repo)
+
+	for len(commits) > 0 {
+		size := 1
+		for size < len(commits) && commits[size].At.Sub(commits[size-1].At) <= cfg.Gap {
+			size++
+		}
+		session := commits[:size]
+		commits = commits[size:]
+
+		first := session[0]
+		start := first.At.Add(-cfg.WarmUp)
+		end := session[len(session)-1].At
+
+		for _, span := range splitByDay(start, end) {
+			externalId := generateGitExternalId(repo, first.Hash, span.Day)
+			timers = append(timers, models.TimerModel{
+				Id:           nil,
+				ExternalId:   &externalId,
+				CreatedAt:    nil,
+				Name:         name,
+				Description:  fmt.Sprintf("%d commits", len(session)),
+				SecondsSpent: span.End.Sub(span.Start),
+				EndedAt:      &span.End,
+				FixatedAt:    span.Day,
+			})
+		}
+	}
+
+	return timers
+}
+
+func generateGitExternalId(repo string, firstCommitHash string, day time.Time) string {
+	if len(firstCommitHash) > 12 {
+		firstCommitHash = firstCommitHash[:12]
+	}
+	return fmt.Sprintf("%s%s:%s", gitRepositoryPrefix(repo), firstCommitHash, day.Format(constnats.DateLayout))
+}
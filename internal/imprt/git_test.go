@@ -0,0 +1,63 @@
+package imprt
+
+import (
+	"gomificator/internal/settings"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateGitSessionTimers(t *testing.T) {
+	cfg := settings.GitImportConfig{Gap: time.Hour, WarmUp: 30 * time.Minute}
+	commits := []gitCommit{
+		{Hash: "0123456789abcdef", At: time.Date(2025, 11, 3, 10, 0, 0, 0, time.UTC)},
+		{Hash: "fedcba9876543210", At: time.Date(2025, 11, 3, 10, 40, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name     string
+		repo     string
+		wantId   string
+		wantName string
+	}{
+		{
+			name:     "work repository",
+			repo:     "/home/me/work/api",
+			wantId:   "Git:/home/me/work/api:0123456789ab:2025-11-03",
+			wantName: "api",
+		},
+		{
+			name:     "repository of the same name elsewhere",
+			repo:     "/home/me/oss/api",
+			wantId:   "Git:/home/me/oss/api:0123456789ab:2025-11-03",
+			wantName: "api",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timers := createGitSessionTimers(tt.repo, commits, cfg)
+			if len(timers) != 1 {
+				t.Fatalf("got %d timers, want 1", len(timers))
+			}
+			timer := timers[0]
+			if *timer.ExternalId != tt.wantId {
+				t.Errorf("external id = %q, want %q", *timer.ExternalId, tt.wantId)
+			}
+			if timer.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", timer.Name, tt.wantName)
+			}
+			if timer.SecondsSpent != 70*time.Minute {
+				t.Errorf("seconds spent = %v, want %v", timer.SecondsSpent, 70*time.Minute)
+			}
+			// reconciling one repository must not reach the timers of the other
+			if !strings.HasPrefix(*timer.ExternalId, gitRepositoryPrefix(tt.repo)) {
+				t.Errorf("external id %q outside of the prefix %q", *timer.ExternalId, gitRepositoryPrefix(tt.repo))
+			}
+			for _, other := range tests {
+				if other.repo != tt.repo && strings.HasPrefix(*timer.ExternalId, gitRepositoryPrefix(other.repo)) {
+					t.Errorf("external id %q inside the prefix of %s", *timer.ExternalId, other.repo)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package imprt
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StdinPath is the input path that makes OpenInput read from standard input.
+const StdinPath = "-"
+
+// OpenInput opens an import source. Besides plain files it accepts StdinPath,
+// gzip-compressed files (.gz) and zip archives (.zip) holding a single file.
+func OpenInput(path string) (io.ReadCloser, error) {
+	if path == StdinPath {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		return openGzipInput(path)
+	case ".zip":
+		return openZipInput(path)
+	default:
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+		return file, nil
+	}
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func openGzipInput(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("gzip %s: %w", path, err)
+	}
+	return &multiCloser{Reader: gz, closers: []io.Closer{gz, file}}, nil
+}
+
+func openZipInput(path string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("open zip %s: %w", path, err)
+	}
+
+	var entries []*zip.File
+	for _, f := range archive.File {
+		if !f.FileInfo().IsDir() {
+			entries = append(entries, f)
+		}
+	}
+	if len(entries) != 1 {
+		archive.Close()
+		return nil, fmt.Errorf("zip %s: expected exactly one file, found %d", path, len(entries))
+	}
+
+	entry, err := entries[0].Open()
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("open zip entry %s: %w", entries[0].Name, err)
+	}
+	return &multiCloser{Reader: entry, closers: []io.Closer{entry, archive}}, nil
+}
@@ -0,0 +1,157 @@
+package imprt
+
+import (
+	"bufio"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const orgMode = "Org"
+
+const orgTimestampLayout = "2006-01-02 15:04"
+
+var (
+	orgHeadingRe  = regexp.MustCompile(`^(\*+)\s+(.*)$`)
+	orgClockRe    = regexp.MustCompile(`^\s*CLOCK:\s*\[(\d{4}-\d{2}-\d{2})[^\]]*?(\d{1,2}:\d{2})\]--\[(\d{4}-\d{2}-\d{2})[^\]]*?(\d{1,2}:\d{2})\]`)
+	orgTagsRe     = regexp.MustCompile(`\s+(:[\w@#%]+)+:\s*$`)
+	orgPriorityRe = regexp.MustCompile(`^\[#[A-Za-z0-9]\]\s*`)
+)
+
+var orgTodoKeywords = []string{"TODO", "NEXT", "STARTED", "WAITING", "HOLD", "DONE", "CANCELED", "CANCELLED"}
+
+func init() {
+	Register(SourceOrg, func(r io.Reader, path string, _ settings.ImportersConfig) Importer {
+		return NewImporterFromOrgFile(r, path)
+	}, ".org")
+}
+
+type importerOrgFile struct {
+	orgFile io.Reader
+	// source identifies the file in external ids, see orgSource.
+	source string
+}
+
+// NewImporterFromOrgFile creates an importer for CLOCK entries of an org-mode document
+// read from path. Every entry is attributed to its enclosing heading and split at midnight.
+func NewImporterFromOrgFile(file io.Reader, path string) Importer {
+	return &importerOrgFile{
+		orgFile: file,
+		source:  orgSource(path),
+	}
+}
+
+// orgSource is the absolute path of an org file, so the same file imported
+// from another working directory keeps its ids.
+func orgSource(path string) string {
+	if path == StdinPath || path == "" {
+		return StdinPath
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func (i *importerOrgFile) Import() ([]models.TimerModel, error) {
+	var timers []models.TimerModel
+	var headings []string
+	// identical CLOCK lines under the same heading get numbered ids
+	seen := make(map[string]int)
+
+	scanner := bufio.NewScanner(i.orgFile)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		if match := orgHeadingRe.FindStringSubmatch(line); match != nil {
+			level := len(match[1])
+			if level > len(headings) {
+				headings = append(headings, make([]string, level-len(headings))...)
+			}
+			headings = headings[:level]
+			headings[level-1] = cleanOrgHeading(match[2])
+			continue
+		}
+
+		match := orgClockRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		start, err := time.ParseInLocation(orgTimestampLayout, match[1]+" "+match[2], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse clock start: %w", lineNum, err)
+		}
+		end, err := time.ParseInLocation(orgTimestampLayout, match[3]+" "+match[4], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse clock end: %w", lineNum, err)
+		}
+
+		name := ""
+		if len(headings) > 0 {
+			name = headings[len(headings)-1]
+		}
+
+		for _, span := range splitByDay(start, end) {
+			externalId := generateOrgExternalId(i.source, headings, start, end, span.Day)
+			seen[externalId]++
+			if n := seen[externalId]; n > 1 {
+				externalId = fmt.Sprintf("%s#%d", externalId, n)
+			}
+			timers = append(timers, models.TimerModel{
+				Id:           nil,
+				ExternalId:   &externalId,
+				CreatedAt:    nil,
+				Name:         name,
+				Description:  strings.Join(headings, " / "),
+				SecondsSpent: span.End.Sub(span.Start),
+				EndedAt:      &span.End,
+				FixatedAt:    span.Day,
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	return timers, nil
+}
+
+// ExternalIdPrefixes limits reconciliation to the entries of the imported file.
+func (i *importerOrgFile) ExternalIdPrefixes() []string {
+	return []string{orgFilePrefix(i.source)}
+}
+
+func orgFilePrefix(source string) string {
+	return fmt.Sprintf("%s:%s:", orgMode, source)
+}
+
+// cleanOrgHeading strips TODO keywords, priority cookies and tags from a heading title.
+func cleanOrgHeading(title string) string {
+	title = orgTagsRe.ReplaceAllString(title, "")
+	for _, keyword := range orgTodoKeywords {
+		if rest, ok := strings.CutPrefix(title, keyword+" "); ok {
+			title = rest
+			break
+		}
+	}
+	title = orgPriorityRe.ReplaceAllString(title, "")
+	return strings.TrimSpace(title)
+}
+
+// generateOrgExternalId identifies a clock entry by its file, heading path,
+// start and end, so entries starting in the same minute under different
+// headings or in different files don't overwrite each other.
+func generateOrgExternalId(source string, headings []string, start, end time.Time, day time.Time) string {
+	return fmt.Sprintf("%s%s:%s--%s:%s", orgFilePrefix(source), strings.Join(headings, "/"),
+		start.Format("20060102T1504"), end.Format("20060102T1504"), day.Format(constnats.DateLayout))
+}
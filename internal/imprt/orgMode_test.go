@@ -0,0 +1,161 @@
+package imprt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateOrgExternalId(t *testing.T) {
+	start := time.Date(2025, 11, 3, 9, 0, 0, 0, time.Local)
+	end := time.Date(2025, 11, 3, 10, 30, 0, 0, time.Local)
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		source   string
+		headings []string
+		want     string
+	}{
+		{
+			name:     "nested headings",
+			source:   "/notes/work.org",
+			headings: []string{"Project", "Task"},
+			want:     "Org:/notes/work.org:Project/Task:20251103T0900--20251103T1030:2025-11-03",
+		},
+		{
+			name:     "no heading",
+			source:   "/notes/work.org",
+			headings: nil,
+			want:     "Org:/notes/work.org::20251103T0900--20251103T1030:2025-11-03",
+		},
+		{
+			name:     "stdin",
+			source:   StdinPath,
+			headings: []string{"Task"},
+			want:     "Org:" + StdinPath + ":Task:20251103T0900--20251103T1030:2025-11-03",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generateOrgExternalId(tt.source, tt.headings, start, end, day)
+			if got != tt.want {
+				t.Errorf("generateOrgExternalId() = %q, want %q", got, tt.want)
+			}
+			if !strings.HasPrefix(got, orgFilePrefix(tt.source)) {
+				t.Errorf("id %q is outside of the file prefix %q", got, orgFilePrefix(tt.source))
+			}
+		})
+	}
+}
+
+func TestCleanOrgHeading(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Write report", want: "Write report"},
+		{title: "TODO Write report", want: "Write report"},
+		{title: "DONE [#A] Write report :work:urgent:", want: "Write report"},
+		{title: "NEXT Write report   :@home:", want: "Write report"},
+		{title: "TODOS are kept", want: "TODOS are kept"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := cleanOrgHeading(tt.title); got != tt.want {
+				t.Errorf("cleanOrgHeading(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrgImport(t *testing.T) {
+	type timer struct {
+		id      string
+		name    string
+		minutes int
+	}
+	prefix := orgFilePrefix("/notes/work.org")
+
+	tests := []struct {
+		name string
+		doc  string
+		want []timer
+	}{
+		{
+			name: "same start under different headings",
+			doc: `* Project
+** Task A
+CLOCK: [2025-11-03 Mon 09:00]--[2025-11-03 Mon 10:00] =>  1:00
+** TODO Task B :work:
+CLOCK: [2025-11-03 Mon 09:00]--[2025-11-03 Mon 09:30] =>  0:30
+`,
+			want: []timer{
+				{id: prefix + "Project/Task A:20251103T0900--20251103T1000:2025-11-03", name: "Task A", minutes: 60},
+				{id: prefix + "Project/Task B:20251103T0900--20251103T0930:2025-11-03", name: "Task B", minutes: 30},
+			},
+		},
+		{
+			name: "duplicate clock lines are numbered",
+			doc: `* Task
+CLOCK: [2025-11-03 Mon 09:00]--[2025-11-03 Mon 09:15] =>  0:15
+CLOCK: [2025-11-03 Mon 09:00]--[2025-11-03 Mon 09:15] =>  0:15
+`,
+			want: []timer{
+				{id: prefix + "Task:20251103T0900--20251103T0915:2025-11-03", name: "Task", minutes: 15},
+				{id: prefix + "Task:20251103T0900--20251103T0915:2025-11-03#2", name: "Task", minutes: 15},
+			},
+		},
+		{
+			name: "split at midnight",
+			doc: `* Task
+CLOCK: [2025-11-03 Mon 23:30]--[2025-11-04 Tue 00:45] =>  1:15
+`,
+			want: []timer{
+				{id: prefix + "Task:20251103T2330--20251104T0045:2025-11-03", name: "Task", minutes: 30},
+				{id: prefix + "Task:20251103T2330--20251104T0045:2025-11-04", name: "Task", minutes: 45},
+			},
+		},
+		{
+			name: "no clock lines",
+			doc:  "* Task\nSome notes\n",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importer := &importerOrgFile{orgFile: strings.NewReader(tt.doc), source: "/notes/work.org"}
+			timers, err := importer.Import()
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if len(timers) != len(tt.want) {
+				t.Fatalf("Import() returned %d timers, want %d", len(timers), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := timers[i]
+				if *got.ExternalId != want.id {
+					t.Errorf("timer %d id = %q, want %q", i, *got.ExternalId, want.id)
+				}
+				if got.Name != want.name {
+					t.Errorf("timer %d name = %q, want %q", i, got.Name, want.name)
+				}
+				if minutes := int(got.SecondsSpent.Minutes()); minutes != want.minutes {
+					t.Errorf("timer %d minutes = %d, want %d", i, minutes, want.minutes)
+				}
+			}
+		})
+	}
+}
+
+func TestOrgSource(t *testing.T) {
+	if got := orgSource(StdinPath); got != StdinPath {
+		t.Errorf("orgSource(stdin) = %q, want %q", got, StdinPath)
+	}
+	if got := orgSource(""); got != StdinPath {
+		t.Errorf("orgSource(\"\") = %q, want %q", got, StdinPath)
+	}
+	if got := orgSource("notes/work.org"); !strings.HasSuffix(got, "/notes/work.org") || !strings.HasPrefix(got, "/") {
+		t.Errorf("orgSource(relative) = %q, want an absolute path", got)
+	}
+}
@@ -0,0 +1,145 @@
+package imprt
+
+import (
+	"fmt"
+	"gomificator/internal/settings"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Factory creates an importer reading its source from r, path is where r was
+// opened from or StdinPath.
+type Factory func(r io.Reader, path string, cfg settings.ImportersConfig) Importer
+
+// DirectoryFactory creates an importer for a directory taken as a whole, e.g.
+// a git repository.
+type DirectoryFactory func(dir string, cfg settings.ImportersConfig) Importer
+
+type registration struct {
+	factory    Factory
+	dirFactory DirectoryFactory
+	// extensions select the files of a directory the importer reads.
+	extensions []string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register makes an importer of files available under name. Files with one of
+// extensions are picked from directories, every file if there are none. It
+// panics if the name is already taken, as registrations happen in init functions.
+func Register(name string, factory Factory, extensions ...string) {
+	if factory == nil {
+		panic(fmt.Sprintf("imprt: register nil factory for %q", name))
+	}
+	register(name, registration{factory: factory, extensions: extensions})
+}
+
+// RegisterDirectory makes an importer of whole directories available under name.
+func RegisterDirectory(name string, factory DirectoryFactory) {
+	if factory == nil {
+		panic(fmt.Sprintf("imprt: register nil factory for %q", name))
+	}
+	register(name, registration{dirFactory: factory})
+}
+
+func register(name string, reg registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("imprt: importer %q registered twice", name))
+	}
+	registry[name] = reg
+}
+
+// NewImporter creates the file importer registered under name.
+func NewImporter(name string, r io.Reader, path string, cfg settings.ImportersConfig) (Importer, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown importer type: %s", name)
+	}
+	if reg.factory == nil {
+		return nil, fmt.Errorf("importer %s reads a directory, not a file", name)
+	}
+	return reg.factory(r, path, cfg), nil
+}
+
+// NewDirectoryImporter creates the directory importer registered under name.
+func NewDirectoryImporter(name string, dir string, cfg settings.ImportersConfig) (Importer, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown importer type: %s", name)
+	}
+	if reg.dirFactory == nil {
+		return nil, fmt.Errorf("importer %s reads files, not a directory", name)
+	}
+	return reg.dirFactory(dir, cfg), nil
+}
+
+// ReadsDirectories tells whether the importer registered under name takes a
+// directory as a whole instead of the files in it.
+func ReadsDirectories(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return registry[name].dirFactory != nil
+}
+
+// MatchesExtensions tells whether the importer registered under name reads
+// path when it is found in a directory. SourceAuto matches the extensions of
+// every file importer, ones without extensions are never detected. Compressed
+// files are matched by the name they were packed with.
+func MatchesExtensions(name string, path string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var extensions []string
+	if name == SourceAuto {
+		for _, reg := range registry {
+			extensions = append(extensions, reg.extensions...)
+		}
+	} else {
+		reg, ok := registry[name]
+		if !ok || reg.factory == nil {
+			return false
+		}
+		if len(reg.extensions) == 0 {
+			return true
+		}
+		extensions = reg.extensions
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".zip":
+		return true
+	case ".gz":
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path))))
+	}
+	return slices.Contains(extensions, ext)
+}
+
+// Names returns the sorted names of all registered importers.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -5,12 +5,22 @@ import (
 	"fmt"
 	"gomificator/internal/constnats"
 	"gomificator/internal/models"
-	"os"
+	"gomificator/internal/settings"
+	"io"
 	"time"
 )
 
 const superProductivity = "SuperProductivity"
 
+func init() {
+	Register(SourceSuperProductivityExport, func(r io.Reader, _ string, cfg settings.ImportersConfig) Importer {
+		return NewImporterFromSuperProductivityExportFile(r, cfg.SuperProductivity)
+	}, ".json")
+	Register(SourceSuperProductivityBackup, func(r io.Reader, _ string, cfg settings.ImportersConfig) Importer {
+		return NewImporterFromSuperProductivityBackupFile(r, cfg.SuperProductivity)
+	}, ".json")
+}
+
 type backupFileContent struct {
 	Data dataContent `json:"data"`
 }
@@ -19,6 +29,18 @@ type dataContent struct {
 	Task         taskEntry    `json:"task"`
 	ArchiveOld   archiveEntry `json:"archiveOld"`
 	ArchiveYoung archiveEntry `json:"archiveYoung"`
+	Project      titledEntry  `json:"project"`
+	Tag          titledEntry  `json:"tag"`
+}
+
+// titledEntry holds SP entities of which only the title is needed, such as projects and tags.
+type titledEntry struct {
+	Ids      []string                `json:"ids"`
+	Entities map[string]titledEntity `json:"entities"`
+}
+
+type titledEntity struct {
+	Title string `json:"title"`
 }
 
 type archiveEntry struct {
@@ -35,15 +57,22 @@ type taskEntity struct {
 	Title          string         `json:"title"`
 	Description    string         `json:"notes"`
 	ParentId       string         `json:"parentId"`
+	ProjectId      string         `json:"projectId"`
+	TagIds         []string       `json:"tagIds"`
+	IsDone         bool           `json:"isDone"`
+	DoneOn         *int64         `json:"doneOn"`
 }
 
 type impoerterSuperProductivityExportFile struct {
-	exportFile *os.File
+	exportFile  io.Reader
+	cfg         settings.SuperProductivityImportConfig
+	completions []models.TaskCompletionModel
 }
 
-func NewImporterFromSuperProductivityExportFile(file *os.File) Importer {
+func NewImporterFromSuperProductivityExportFile(file io.Reader, cfg settings.SuperProductivityImportConfig) Importer {
 	return &impoerterSuperProductivityExportFile{
 		exportFile: file,
+		cfg:        cfg,
 	}
 }
 
@@ -58,22 +87,34 @@ func (i *impoerterSuperProductivityExportFile) Import() ([]models.TimerModel, er
 
 	allEntities := extractAllEntities(backupContent.Data)
 
-	timers, err := createBatchTimers(allEntities)
+	timers, err := createBatchTimers(allEntities, newSpRelations(backupContent.Data), i.cfg.Subtasks)
 	if err != nil {
 		return nil, fmt.Errorf("create batch timers: %w", err)
 	}
+	i.completions = createCompletions(allEntities)
 
 	return timers, nil
 
 }
 
+func (i *impoerterSuperProductivityExportFile) ExternalIdPrefixes() []string {
+	return []string{superProductivity + ":"}
+}
+
+func (i *impoerterSuperProductivityExportFile) Completions() []models.TaskCompletionModel {
+	return i.completions
+}
+
 type impoerterSuperProductivityBackupFile struct {
-	backupFile *os.File
+	backupFile  io.Reader
+	cfg         settings.SuperProductivityImportConfig
+	completions []models.TaskCompletionModel
 }
 
-func NewImporterFromSuperProductivityBackupFile(file *os.File) Importer {
+func NewImporterFromSuperProductivityBackupFile(file io.Reader, cfg settings.SuperProductivityImportConfig) Importer {
 	return &impoerterSuperProductivityBackupFile{
 		backupFile: file,
+		cfg:        cfg,
 	}
 }
 
@@ -88,15 +129,24 @@ func (i *impoerterSuperProductivityBackupFile) Import() ([]models.TimerModel, er
 
 	allEntities := extractAllEntities(dataContent)
 
-	timers, err := createBatchTimers(allEntities)
+	timers, err := createBatchTimers(allEntities, newSpRelations(dataContent), i.cfg.Subtasks)
 	if err != nil {
 		return nil, fmt.Errorf("create batch timers: %w", err)
 	}
+	i.completions = createCompletions(allEntities)
 
 	return timers, nil
 
 }
 
+func (i *impoerterSuperProductivityBackupFile) ExternalIdPrefixes() []string {
+	return []string{superProductivity + ":"}
+}
+
+func (i *impoerterSuperProductivityBackupFile) Completions() []models.TaskCompletionModel {
+	return i.completions
+}
+
 // general functions
 func extractAllEntities(dc dataContent) map[string]taskEntity {
 	total := 0
@@ -125,41 +175,137 @@ func extractAllEntities(dc dataContent) map[string]taskEntity {
 	return out
 }
 
-func createBatchTimers(entities map[string]taskEntity) ([]models.TimerModel, error) {
+// createBatchTimers turns per-day task time into timers. SP sums subtask time
+// into the parent, subtasksMode decides which level keeps it.
+func createBatchTimers(entities map[string]taskEntity, relations spRelations, subtasksMode string) ([]models.TimerModel, error) {
 	var timers []models.TimerModel
 
+	attributeToSubtasks := subtasksMode == settings.SubtasksOnly || subtasksMode == settings.SubtasksBoth
+
+	// time of subtasks per parent and day, taken away from the parent total
+	subtasksTime := make(map[string]map[string]int)
+	if attributeToSubtasks {
+		for _, task := range entities {
+			if task.ParentId == "" {
+				continue
+			}
+			if subtasksTime[task.ParentId] == nil {
+				subtasksTime[task.ParentId] = make(map[string]int)
+			}
+			for dateStr, spent := range task.TimeSpentOnDay {
+				subtasksTime[task.ParentId][dateStr] += spent
+			}
+		}
+	}
+
 	for taskId, task := range entities {
 		isSubtask := false
 		if task.ParentId != "" {
 			isSubtask = true
 		}
 
-		for dateStr, seconds := range task.TimeSpentOnDay {
+		for dateStr, spent := range task.TimeSpentOnDay {
 			var externalIdPtr string = generateExternalId(taskId, dateStr)
 			fixatedAt, err := time.Parse(constnats.DateLayout, dateStr)
 			if err != nil {
 				return nil, fmt.Errorf("parse date %s: %w", dateStr, err)
 			}
+
 			var secondsSpent time.Duration
-			if !isSubtask {
-				secondsSpent = time.Millisecond * time.Duration(seconds)
+			var parentExternalId *string
+			switch {
+			case !isSubtask:
+				secondsSpent = time.Millisecond * time.Duration(max(spent-subtasksTime[taskId][dateStr], 0))
+			case attributeToSubtasks:
+				secondsSpent = time.Millisecond * time.Duration(spent)
+				if subtasksMode == settings.SubtasksBoth {
+					parentId := generateExternalId(task.ParentId, dateStr)
+					parentExternalId = &parentId
+				}
 			}
 
-			timers = append(timers, models.TimerModel{
-				Id:           nil,
-				ExternalId:   &externalIdPtr,
-				CreatedAt:    nil,
-				Name:         task.Title,
-				Description:  task.Description,
-				SecondsSpent: secondsSpent,
-				FixatedAt:    fixatedAt,
-			})
+			timer := models.TimerModel{
+				Id:               nil,
+				ExternalId:       &externalIdPtr,
+				ParentExternalId: parentExternalId,
+				CreatedAt:        nil,
+				Name:             task.Title,
+				Description:      task.Description,
+				SecondsSpent:     secondsSpent,
+				FixatedAt:        fixatedAt,
+			}
+			relations.attach(&timer, task, entities)
+			timers = append(timers, timer)
 		}
 	}
 
 	return timers, nil
 }
 
+// createCompletions collects done tasks. Tasks done before SP started to
+// record doneOn can't be placed on a day and are skipped.
+func createCompletions(entities map[string]taskEntity) []models.TaskCompletionModel {
+	var completions []models.TaskCompletionModel
+	for taskId, task := range entities {
+		if !task.IsDone || task.DoneOn == nil {
+			continue
+		}
+		doneAt := time.UnixMilli(*task.DoneOn).Local()
+		completions = append(completions, models.TaskCompletionModel{
+			ExternalId: generateEntityExternalId("done", taskId),
+			Title:      task.Title,
+			DoneAt:     doneAt,
+			Day:        time.Date(doneAt.Year(), doneAt.Month(), doneAt.Day(), 0, 0, 0, 0, time.UTC),
+		})
+	}
+	return completions
+}
+
+// spRelations resolves project and tag ids of tasks.
+type spRelations struct {
+	projects map[string]*models.ProjectModel
+	tags     map[string]models.TagModel
+}
+
+func newSpRelations(dc dataContent) spRelations {
+	r := spRelations{
+		projects: make(map[string]*models.ProjectModel, len(dc.Project.Entities)),
+		tags:     make(map[string]models.TagModel, len(dc.Tag.Entities)),
+	}
+	for id, project := range dc.Project.Entities {
+		r.projects[id] = &models.ProjectModel{ExternalId: generateEntityExternalId("project", id), Title: project.Title}
+	}
+	for id, tag := range dc.Tag.Entities {
+		r.tags[id] = models.TagModel{ExternalId: generateEntityExternalId("tag", id), Title: tag.Title}
+	}
+	return r
+}
+
+// attach links the timer to the project and tags of its task. Subtasks in SP
+// usually carry no tags of their own, so they inherit the parent's.
+func (r spRelations) attach(timer *models.TimerModel, task taskEntity, entities map[string]taskEntity) {
+	if parent, ok := entities[task.ParentId]; ok && task.ParentId != "" {
+		if task.ProjectId == "" {
+			task.ProjectId = parent.ProjectId
+		}
+		if len(task.TagIds) == 0 {
+			task.TagIds = parent.TagIds
+		}
+	}
+
+	timer.Project = r.projects[task.ProjectId]
+	timer.Tags = make([]models.TagModel, 0, len(task.TagIds))
+	for _, tagId := range task.TagIds {
+		if tag, ok := r.tags[tagId]; ok {
+			timer.Tags = append(timer.Tags, tag)
+		}
+	}
+}
+
+func generateEntityExternalId(kind string, id string) string {
+	return fmt.Sprintf("%s:%s:%s", superProductivity, kind, id)
+}
+
 func generateExternalId(taskId string, dateStr string) string {
 	return fmt.Sprintf("%s:%s:%s", superProductivity, taskId, dateStr)
 }
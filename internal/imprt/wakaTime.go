@@ -0,0 +1,96 @@
+package imprt
+
+import (
+	"encoding/json"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"io"
+	"time"
+)
+
+const wakaTime = "WakaTime"
+
+func init() {
+	Register(SourceWakaTime, func(r io.Reader, _ string, _ settings.ImportersConfig) Importer {
+		return NewImporterFromWakaTimeDumpFile(r)
+	}, ".json")
+}
+
+type wakaTimeDump struct {
+	Days []wakaTimeDay `json:"days"`
+}
+
+type wakaTimeDay struct {
+	Date     string            `json:"date"`
+	Projects []wakaTimeProject `json:"projects"`
+}
+
+type wakaTimeProject struct {
+	Name       string `json:"name"`
+	GrandTotal struct {
+		TotalSeconds float64 `json:"total_seconds"`
+	} `json:"grand_total"`
+}
+
+type importerWakaTimeDumpFile struct {
+	dumpFile io.Reader
+	days     DayRange
+}
+
+// NewImporterFromWakaTimeDumpFile creates an importer for a WakaTime data dump,
+// producing one timer per project and day.
+func NewImporterFromWakaTimeDumpFile(file io.Reader) Importer {
+	return &importerWakaTimeDumpFile{
+		dumpFile: file,
+	}
+}
+
+func (i *importerWakaTimeDumpFile) Import() ([]models.TimerModel, error) {
+	var dump wakaTimeDump
+	if err := json.NewDecoder(i.dumpFile).Decode(&dump); err != nil {
+		return nil, fmt.Errorf("decode dump file: %w", err)
+	}
+
+	var timers []models.TimerModel
+	i.days = DayRange{}
+	for _, day := range dump.Days {
+		fixatedAt, err := time.Parse(constnats.DateLayout, day.Date)
+		if err != nil {
+			return nil, fmt.Errorf("parse date %s: %w", day.Date, err)
+		}
+		i.days = i.days.extend(fixatedAt)
+
+		for _, project := range day.Projects {
+			if project.GrandTotal.TotalSeconds <= 0 {
+				continue
+			}
+			externalId := generateWakaTimeExternalId(project.Name, day.Date)
+			timers = append(timers, models.TimerModel{
+				Id:           nil,
+				ExternalId:   &externalId,
+				CreatedAt:    nil,
+				Name:         project.Name,
+				Description:  "",
+				SecondsSpent: time.Duration(project.GrandTotal.TotalSeconds * float64(time.Second)).Round(time.Second),
+				FixatedAt:    fixatedAt,
+			})
+		}
+	}
+
+	return timers, nil
+}
+
+func (i *importerWakaTimeDumpFile) ExternalIdPrefixes() []string {
+	return []string{wakaTime + ":"}
+}
+
+// DayRange is the range of days listed in the dump.
+func (i *importerWakaTimeDumpFile) DayRange() (DayRange, bool) {
+	return i.days, !i.days.From.IsZero()
+}
+
+func generateWakaTimeExternalId(project string, dateStr string) string {
+	return fmt.Sprintf("%s:%s:%s", wakaTime, project, dateStr)
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TaskCompletionModel records that a task of an external source was done.
+type TaskCompletionModel struct {
+	Id         *int
+	ExternalId string
+	Title      string
+	DoneAt     time.Time
+	Day        time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ImportedFileModel remembers the state of a file at its last successful import.
+type ImportedFileModel struct {
+	Path        string
+	Size        int64
+	ModTime     time.Time
+	ContentHash string
+	ImportedAt  *time.Time
+}
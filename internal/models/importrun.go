@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Import run statuses.
+const (
+	ImportRunRunning = "running"
+	ImportRunDone    = "done"
+	ImportRunFailed  = "failed"
+	ImportRunUndone  = "undone"
+)
+
+// ImportRunModel is one import of a file or directory into the database.
+type ImportRunModel struct {
+	Id         int
+	Source     string
+	File       string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Inserted   int
+	Updated    int
+	Unchanged  int
+	Deleted    int
+	Status     string
+	Error      string
+}
@@ -0,0 +1,15 @@
+package models
+
+// ProjectModel is a project of the source a timer was imported from.
+type ProjectModel struct {
+	Id         *int
+	ExternalId string
+	Title      string
+}
+
+// TagModel is a tag of the source a timer was imported from.
+type TagModel struct {
+	Id         *int
+	ExternalId string
+	Title      string
+}
@@ -0,0 +1,17 @@
+package models
+
+// DayRulesModel is the day type a day was settled with, frozen so that later
+// changes of the settings don't rewrite its rewards.
+type DayRulesModel struct {
+	DayType string
+	// Rules is the day type as YAML, Hash its hex encoded SHA-256.
+	Rules string
+	Hash  string
+}
+
+// PeriodRulesModel are the weekly or monthly goals a period was settled with.
+type PeriodRulesModel struct {
+	// Rules are the goals as YAML, Hash its hex encoded SHA-256.
+	Rules string
+	Hash  string
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"gomificator/internal/constnats"
+	"time"
+)
+
+// Streak statuses of a settled day.
+const (
+	// DayMet days extend a streak.
+	DayMet = "met"
+	// DayMissed days end a streak.
+	DayMissed = "missed"
+	// DayFrozen days were missed, but a streak freeze kept the streak.
+	DayFrozen = "frozen"
+	// DayNeutral days have no daily goals and neither extend nor end a streak.
+	DayNeutral = "neutral"
+)
+
+// DayStatusModel is the streak status of a settled day.
+type DayStatusModel struct {
+	Day    time.Time
+	Status string
+}
+
+// StreakRewardModel is a streak milestone granted to the streak that
+// started on Start.
+type StreakRewardModel struct {
+	Start time.Time
+	Days  int
+	Medal constnats.Medal
+	Count int
+}
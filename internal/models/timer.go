@@ -3,11 +3,20 @@ package models
 import "time"
 
 type TimerModel struct {
-	Id           *int
-	ExternalId   *string
-	CreatedAt    *time.Time
-	Name         string
-	Description  string
-	FixatedAt    time.Time
-	SecondsSpent time.Duration
+	Id         *int
+	ExternalId *string
+	// ParentExternalId links a subtask timer to the timer of its parent task.
+	ParentExternalId *string
+	CreatedAt        *time.Time
+	Name             string
+	Description      string
+	FixatedAt        time.Time
+	SecondsSpent     time.Duration
+	// EndedAt is when the tracked interval ended, nil for sources that only
+	// know a total per day.
+	EndedAt   *time.Time
+	DeletedAt *time.Time
+	Project   *ProjectModel
+	// Tags is nil when the source has no tags, an empty slice clears stored ones.
+	Tags []TagModel
 }
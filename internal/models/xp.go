@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Sources of XP events.
+const (
+	XPMinutes = "minutes"
+	XPTasks   = "tasks"
+	// XPMedals are the medals earned by the daily goals.
+	XPMedals = "medals"
+	XPStreak = "streak"
+	// XPWeek and XPMonth are the medals earned by the weekly and monthly
+	// goals, recorded on the last day of the period.
+	XPWeek  = "week"
+	XPMonth = "month"
+	// XPMilestones are the medals of streak milestones, recorded on the day
+	// a milestone was reached.
+	XPMilestones = "milestones"
+)
+
+// XPEventModel is the XP a day earned from one source.
+type XPEventModel struct {
+	Day    time.Time
+	Source string
+	XP     int
+}
+
+// LevelUpModel is the day a level was reached.
+type LevelUpModel struct {
+	Level int
+	Name  string
+	Day   time.Time
+}
@@ -0,0 +1,68 @@
+// Package pidfile keeps a single running instance per lock file.
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned by Acquire when another process holds the lock.
+var ErrLocked = errors.New("pidfile: locked by another process")
+
+// PidFile is a held lock, the file contains the PID of the holder.
+type PidFile struct {
+	path string
+	file *os.File
+}
+
+// Acquire creates or opens path, locks it and writes the current PID into it.
+func Acquire(path string) (*PidFile, error) {
+	file, err := lock(path)
+	if err != nil {
+		if errors.Is(err, ErrLocked) {
+			if pid := readPid(path); pid != 0 {
+				return nil, fmt.Errorf("%w (pid %d)", ErrLocked, pid)
+			}
+		}
+		return nil, err
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("truncate %s: %w", path, err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write %s: %w", path, err)
+	}
+	return &PidFile{path: path, file: file}, nil
+}
+
+// Release removes the file and drops the lock.
+func (p *PidFile) Release() error {
+	// removing before closing keeps another process from locking a file that
+	// is about to disappear
+	removeErr := os.Remove(p.path)
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", p.path, err)
+	}
+	if removeErr != nil && !os.IsNotExist(removeErr) {
+		return fmt.Errorf("remove %s: %w", p.path, removeErr)
+	}
+	return nil
+}
+
+func readPid(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package pidfile
+
+import (
+	"fmt"
+	"os"
+)
+
+// lock relies on exclusive creation, a stale file left by a crash has to be
+// removed by hand.
+func lock(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,29 @@
+package rewards
+
+import (
+	"gomificator/internal/models"
+	"time"
+)
+
+// restAfterDeadline is the restafter time of a goal on the given day.
+func restAfterDeadline(day time.Time, restAfter time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), restAfter.Hour(), restAfter.Minute(), 0, 0, time.Local)
+}
+
+// minutesBefore sums the minutes tracked before deadline. A timer is taken as
+// one interval ending at EndedAt, timers without an end time can't be placed
+// within the day and count in full.
+func minutesBefore(timers []models.TimerModel, deadline time.Time) int {
+	total := time.Duration(0)
+	for _, t := range timers {
+		if t.EndedAt == nil || !t.EndedAt.After(deadline) {
+			total += t.SecondsSpent
+			continue
+		}
+		start := t.EndedAt.Add(-t.SecondsSpent)
+		if start.Before(deadline) {
+			total += deadline.Sub(start)
+		}
+	}
+	return int(total.Minutes())
+}
@@ -0,0 +1,92 @@
+package rewards
+
+import (
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"time"
+)
+
+// ErrShopBlocked is returned when a penalty blocks shop purchases.
+var ErrShopBlocked = errors.New("rewards: shop purchases are blocked by a penalty")
+
+// previewPenalties applies the penalties of the day type to a previewed day,
+// the wallet floor is left to floorPenalty.
+func previewPenalties(dayType settings.DayType, result *DayResult) {
+	result.Penalty = make(models.WalletModel)
+	for _, penalty := range dayType.Penalties {
+		if result.Minutes >= penalty.Minutes && result.TasksDone >= penalty.Tasks {
+			continue
+		}
+		if penalty.Medal != nil {
+			result.Penalty[*penalty.Medal] -= penalty.Count
+		}
+		if penalty.BlockShop {
+			// days are settled the day after, which stays blocked to its end
+			until := result.Day.AddDate(0, 0, 2)
+			result.ShopBlockedUntil = &until
+		}
+	}
+}
+
+// floorPenalty cuts the penalty of a day so it doesn't take wallet below the
+// floor of the settings and adds the wallet change of the day to wallet.
+// wallet is what the wallet will be once the days before are settled.
+func (s *Service) floorPenalty(result *DayResult, wallet models.WalletModel) {
+	for medal, cnt := range result.Penalty {
+		// the penalty recorded before is already taken from wallet
+		available := wallet[medal] - result.PreviousPenalty[medal] +
+			result.Earned[medal] - result.Previous[medal] - s.cfg.PenaltyFloor
+		if -cnt > available {
+			result.Penalty[medal] = -max(available, 0)
+			result.PenaltyFloored = true
+		}
+		if result.Penalty[medal] == 0 {
+			delete(result.Penalty, medal)
+		}
+	}
+	result.Delta = walletDiff(walletSum(result.Earned, result.Penalty), walletSum(result.Previous, result.PreviousPenalty))
+
+	for medal, cnt := range result.Delta {
+		wallet[medal] += cnt
+	}
+}
+
+// ShopBlockedUntil returns the first day shop purchases are allowed again, or
+// nil if no penalty blocks them today.
+func (s *Service) ShopBlockedUntil() (*time.Time, error) {
+	until, err := s.strg.PenaltiesRepo.BlockedUntil()
+	if err != nil {
+		return nil, fmt.Errorf("shop blocked until: %w", err)
+	}
+	if until == nil || !s.today().Before(*until) {
+		return nil, nil
+	}
+	return until, nil
+}
+
+// checkShop returns ErrShopBlocked while a penalty blocks shop purchases.
+func (s *Service) checkShop() error {
+	until, err := s.ShopBlockedUntil()
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		return fmt.Errorf("%w, purchases are allowed again on %s", ErrShopBlocked, until.Format(constnats.DateLayout))
+	}
+	return nil
+}
+
+// walletSum returns a + b.
+func walletSum(a, b models.WalletModel) models.WalletModel {
+	sum := make(models.WalletModel, len(a))
+	for medal, cnt := range a {
+		sum[medal] += cnt
+	}
+	for medal, cnt := range b {
+		sum[medal] += cnt
+	}
+	return sum
+}
@@ -0,0 +1,154 @@
+package rewards
+
+import (
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"maps"
+	"testing"
+)
+
+func TestFloorPenalty(t *testing.T) {
+	tests := []struct {
+		name            string
+		wallet          models.WalletModel
+		earned          models.WalletModel
+		previous        models.WalletModel
+		penalty         models.WalletModel
+		previousPenalty models.WalletModel
+		wantPenalty     models.WalletModel
+		wantDelta       models.WalletModel
+		wantWallet      models.WalletModel
+		wantFloored     bool
+	}{
+		{
+			name:        "wallet covers the penalty",
+			wallet:      models.WalletModel{"bronze": 5},
+			penalty:     models.WalletModel{"bronze": -2},
+			wantPenalty: models.WalletModel{"bronze": -2},
+			wantDelta:   models.WalletModel{"bronze": -2},
+			wantWallet:  models.WalletModel{"bronze": 3},
+		},
+		{
+			name:        "penalty cut at the floor",
+			wallet:      models.WalletModel{"bronze": 2},
+			penalty:     models.WalletModel{"bronze": -3},
+			wantPenalty: models.WalletModel{"bronze": -1},
+			wantDelta:   models.WalletModel{"bronze": -1},
+			wantWallet:  models.WalletModel{"bronze": 1},
+			wantFloored: true,
+		},
+		{
+			name:        "wallet at the floor",
+			wallet:      models.WalletModel{"bronze": 1},
+			penalty:     models.WalletModel{"bronze": -2},
+			wantPenalty: models.WalletModel{},
+			wantDelta:   models.WalletModel{},
+			wantWallet:  models.WalletModel{"bronze": 1},
+			wantFloored: true,
+		},
+		{
+			name:        "wallet below the floor",
+			wallet:      models.WalletModel{},
+			penalty:     models.WalletModel{"bronze": -1},
+			wantPenalty: models.WalletModel{},
+			wantDelta:   models.WalletModel{},
+			wantWallet:  models.WalletModel{},
+			wantFloored: true,
+		},
+		{
+			name:        "medals earned the same day count",
+			wallet:      models.WalletModel{"bronze": 1},
+			earned:      models.WalletModel{"bronze": 2},
+			penalty:     models.WalletModel{"bronze": -3},
+			wantPenalty: models.WalletModel{"bronze": -2},
+			wantDelta:   models.WalletModel{},
+			wantWallet:  models.WalletModel{"bronze": 1},
+			wantFloored: true,
+		},
+		{
+			name:            "penalty settled again is taken once",
+			wallet:          models.WalletModel{"bronze": 3},
+			penalty:         models.WalletModel{"bronze": -2},
+			previousPenalty: models.WalletModel{"bronze": -2},
+			wantPenalty:     models.WalletModel{"bronze": -2},
+			wantDelta:       models.WalletModel{},
+			wantWallet:      models.WalletModel{"bronze": 3},
+		},
+		{
+			name:        "other medals are untouched",
+			wallet:      models.WalletModel{"bronze": 1, "gold": 4},
+			previous:    models.WalletModel{"gold": 1},
+			earned:      models.WalletModel{"gold": 1},
+			penalty:     models.WalletModel{"gold": -2},
+			wantPenalty: models.WalletModel{"gold": -2},
+			wantDelta:   models.WalletModel{"gold": -2},
+			wantWallet:  models.WalletModel{"bronze": 1, "gold": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{cfg: &settings.Config{PenaltyFloor: 1}}
+			result := DayResult{
+				Earned:          tt.earned,
+				Previous:        tt.previous,
+				Penalty:         maps.Clone(tt.penalty),
+				PreviousPenalty: tt.previousPenalty,
+			}
+			wallet := maps.Clone(tt.wallet)
+
+			s.floorPenalty(&result, wallet)
+
+			if !maps.Equal(result.Penalty, tt.wantPenalty) {
+				t.Errorf("penalty = %v, want %v", result.Penalty, tt.wantPenalty)
+			}
+			if !maps.Equal(result.Delta, tt.wantDelta) {
+				t.Errorf("delta = %v, want %v", result.Delta, tt.wantDelta)
+			}
+			if !maps.Equal(wallet, tt.wantWallet) {
+				t.Errorf("wallet = %v, want %v", wallet, tt.wantWallet)
+			}
+			if result.PenaltyFloored != tt.wantFloored {
+				t.Errorf("floored = %v, want %v", result.PenaltyFloored, tt.wantFloored)
+			}
+		})
+	}
+}
+
+func TestPreviewPenalties(t *testing.T) {
+	bronze := constnats.Medal("bronze")
+	dayType := settings.DayType{Penalties: []settings.DayPenalty{
+		{Minutes: 60, Count: 1, Medal: &bronze},
+		{Tasks: 1, BlockShop: true},
+	}}
+
+	tests := []struct {
+		name        string
+		minutes     int
+		tasks       int
+		wantPenalty models.WalletModel
+		wantBlocked string
+	}{
+		{name: "both thresholds reached", minutes: 60, tasks: 1, wantPenalty: models.WalletModel{}},
+		{name: "too few minutes", minutes: 59, tasks: 1, wantPenalty: models.WalletModel{"bronze": -1}},
+		{name: "no task done", minutes: 60, tasks: 0, wantPenalty: models.WalletModel{}, wantBlocked: "2025-11-12"},
+		{name: "nothing reached", wantPenalty: models.WalletModel{"bronze": -1}, wantBlocked: "2025-11-12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DayResult{Day: mustDay(t, "2025-11-10"), Minutes: tt.minutes, TasksDone: tt.tasks}
+			previewPenalties(dayType, &result)
+
+			if !maps.Equal(result.Penalty, tt.wantPenalty) {
+				t.Errorf("penalty = %v, want %v", result.Penalty, tt.wantPenalty)
+			}
+			blocked := ""
+			if result.ShopBlockedUntil != nil {
+				blocked = result.ShopBlockedUntil.Format(constnats.DateLayout)
+			}
+			if blocked != tt.wantBlocked {
+				t.Errorf("shop blocked until %q, want %q", blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
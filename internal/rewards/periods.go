@@ -0,0 +1,161 @@
+package rewards
+
+import (
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"slices"
+	"time"
+)
+
+// Periods with goals of their own, settled once they are over.
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// PeriodResult is what a week or a month earned and how that changes its
+// stored rewards.
+type PeriodResult struct {
+	Kind  string
+	Start time.Time
+	End   time.Time
+	// Goals are the goals the period is evaluated against.
+	Goals   []settings.PeriodGoal
+	Minutes int
+	// GoalDays counts the days on which a daily goal was met.
+	GoalDays int
+	Earned   models.WalletModel
+	Previous models.WalletModel
+	Delta    models.WalletModel
+	// RulesChanged is set if the period was settled with goals that differ
+	// from the current settings.
+	RulesChanged bool
+
+	rules models.PeriodRulesModel
+}
+
+// periodBounds returns the first and the last day of the week, starting on
+// Monday, or the month of day.
+func periodBounds(kind string, day time.Time) (time.Time, time.Time) {
+	if kind == PeriodMonth {
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, -1)
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	return start, start.AddDate(0, 0, 6)
+}
+
+// PreviewPeriod evaluates the goals of the week or month containing day
+// without writing anything. Days after today are not counted yet.
+func (s *Service) PreviewPeriod(kind string, day time.Time) (PeriodResult, error) {
+	start, end := periodBounds(kind, day)
+	result := PeriodResult{Kind: kind, Start: start, End: end}
+
+	goals, err := s.periodGoals(&result)
+	if err != nil {
+		return result, err
+	}
+	result.Goals = goals
+
+	timers, err := s.strg.TimersRepo.GetTimersBetweenDates(start, end)
+	if err != nil {
+		return result, fmt.Errorf("timers between dates: %w", err)
+	}
+	total := time.Duration(0)
+	for _, t := range timers {
+		total += t.SecondsSpent
+	}
+	result.Minutes = int(total.Minutes())
+
+	if slices.ContainsFunc(goals, func(g settings.PeriodGoal) bool { return g.Days > 0 }) {
+		today := s.today()
+		for d := start; !d.After(end) && !d.After(today); d = d.AddDate(0, 0, 1) {
+			dayResult, err := s.Preview(d)
+			if err != nil {
+				return result, fmt.Errorf("preview %s: %w", d.Format(constnats.DateLayout), err)
+			}
+			if dayResult.GoalsMet > 0 {
+				result.GoalDays++
+			}
+		}
+	}
+
+	result.Earned = make(models.WalletModel)
+	for _, goal := range goals {
+		if result.Minutes >= goal.Minutes && result.GoalDays >= goal.Days {
+			result.Earned[goal.Medal] += goal.Count
+		}
+	}
+
+	result.Previous, err = s.strg.PeriodRewardsRepo.LoadByPeriod(kind, start)
+	if err != nil {
+		return result, fmt.Errorf("load period rewards: %w", err)
+	}
+	result.Delta = walletDiff(result.Earned, result.Previous)
+	return result, nil
+}
+
+// periodGoals picks the goals a period is evaluated against, like dayRules
+// does for days.
+func (s *Service) periodGoals(result *PeriodResult) ([]settings.PeriodGoal, error) {
+	frozen, err := s.strg.PeriodRewardsRepo.LoadRules(result.Kind, result.Start)
+	if err != nil {
+		return nil, fmt.Errorf("load period rules: %w", err)
+	}
+
+	current := s.cfg.WeeklyGoals
+	if result.Kind == PeriodMonth {
+		current = s.cfg.MonthlyGoals
+	}
+	currentRules, err := freezePeriodGoals(current)
+	if err != nil {
+		return nil, fmt.Errorf("freeze period goals: %w", err)
+	}
+	result.RulesChanged = frozen != nil && frozen.Hash != currentRules.Hash
+
+	if frozen != nil && !s.reapplyRules {
+		goals, err := thawPeriodGoals(*frozen)
+		if err != nil {
+			return nil, fmt.Errorf("goals frozen for the %s of %s: %w", result.Kind, result.Start.Format(constnats.DateLayout), err)
+		}
+		result.rules = *frozen
+		return goals, nil
+	}
+	result.rules = currentRules
+	return current, nil
+}
+
+// previewClosedPeriods evaluates the weeks and months of the days that are
+// over, open ones are settled after their last day.
+func (s *Service) previewClosedPeriods(days []time.Time) ([]PeriodResult, error) {
+	today := s.today()
+
+	var results []PeriodResult
+	for _, kind := range []string{PeriodWeek, PeriodMonth} {
+		var starts []time.Time
+		for _, d := range days {
+			start, end := periodBounds(kind, d)
+			if !end.Before(today) || slices.ContainsFunc(starts, start.Equal) {
+				continue
+			}
+			starts = append(starts, start)
+		}
+		slices.SortFunc(starts, time.Time.Compare)
+
+		for _, start := range starts {
+			result, err := s.PreviewPeriod(kind, start)
+			if err != nil {
+				return results, fmt.Errorf("%s of %s: %w", kind, start.Format(constnats.DateLayout), err)
+			}
+			if len(result.Goals) == 0 && len(result.Previous) == 0 {
+				// nothing to earn or to take back
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,42 @@
+package rewards
+
+import (
+	"gomificator/internal/constnats"
+	"testing"
+	"time"
+)
+
+func TestPeriodBounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		day       string
+		wantStart string
+		wantEnd   string
+	}{
+		{name: "week from monday", kind: PeriodWeek, day: "2025-11-10", wantStart: "2025-11-10", wantEnd: "2025-11-16"},
+		{name: "week from midweek", kind: PeriodWeek, day: "2025-11-13", wantStart: "2025-11-10", wantEnd: "2025-11-16"},
+		{name: "week from sunday", kind: PeriodWeek, day: "2025-11-16", wantStart: "2025-11-10", wantEnd: "2025-11-16"},
+		{name: "week across months", kind: PeriodWeek, day: "2025-10-01", wantStart: "2025-09-29", wantEnd: "2025-10-05"},
+		{name: "week across years", kind: PeriodWeek, day: "2026-01-01", wantStart: "2025-12-29", wantEnd: "2026-01-04"},
+		{name: "month", kind: PeriodMonth, day: "2025-11-13", wantStart: "2025-11-01", wantEnd: "2025-11-30"},
+		{name: "month of 31 days", kind: PeriodMonth, day: "2025-12-31", wantStart: "2025-12-01", wantEnd: "2025-12-31"},
+		{name: "february of a leap year", kind: PeriodMonth, day: "2028-02-10", wantStart: "2028-02-01", wantEnd: "2028-02-29"},
+		{name: "february", kind: PeriodMonth, day: "2026-02-01", wantStart: "2026-02-01", wantEnd: "2026-02-28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, err := time.Parse(constnats.DateLayout, tt.day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, end := periodBounds(tt.kind, day)
+			if got := start.Format(constnats.DateLayout); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format(constnats.DateLayout); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
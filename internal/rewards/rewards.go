@@ -0,0 +1,575 @@
+// Package rewards settles the medals each day earned against the goals of
+// the settings and keeps the wallet in line with them.
+package rewards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"slices"
+	"time"
+)
+
+// DayResult is what a day earned and how that changes its stored rewards.
+type DayResult struct {
+	Day time.Time
+	// Skipped is set if no day type is configured for the weekday, the
+	// stored rewards of the day are left as they are.
+	Skipped   bool
+	DayType   string
+	Minutes   int
+	TasksDone int
+	// LateGoals counts focus goals reached only after their restafter time,
+	// see settings.GoalModeRestAfter.
+	LateGoals int
+	// Goals counts the focus and task goals of the day type, GoalsMet the
+	// ones met in time.
+	Goals    int
+	GoalsMet int
+	// Status is the streak status of the day, see models.DayMet. Previews
+	// don't use streak freezes.
+	Status string
+	Earned models.WalletModel
+	// Previous are the rewards recorded for the day before settlement.
+	Previous models.WalletModel
+	// Penalty are the medals taken by the penalties of the day as negative
+	// counts, PreviousPenalty the ones recorded before. PenaltyFloored is set
+	// if the wallet floor cut the penalty.
+	Penalty         models.WalletModel
+	PreviousPenalty models.WalletModel
+	PenaltyFloored  bool
+	// ShopBlockedUntil is the first day shop purchases are allowed again if a
+	// penalty of the day blocks them.
+	ShopBlockedUntil *time.Time
+	// Delta is Earned and Penalty minus Previous and PreviousPenalty, medals
+	// that didn't change are left out.
+	Delta models.WalletModel
+	// RulesChanged is set if the day was settled with rules that differ from
+	// the current settings.
+	RulesChanged bool
+
+	rules models.DayRulesModel
+}
+
+// Report lists the settled days in order, the weeks and months they closed
+// and their summed wallet change.
+type Report struct {
+	Days    []DayResult
+	Periods []PeriodResult
+	Streak  StreakResult
+	Delta   models.WalletModel
+	// LevelUps are the levels reached for the first time, settlement only.
+	LevelUps []models.LevelUpModel
+}
+
+// Dates returns the dates of the report, in order.
+func (r Report) Dates() []time.Time {
+	dates := make([]time.Time, 0, len(r.Days))
+	for _, d := range r.Days {
+		dates = append(dates, d.Day)
+	}
+	return dates
+}
+
+// Service evaluates days against the goals of cfg and records the rewards in
+// strg. The goals are frozen per day when it is settled the first time, later
+// changes of cfg only apply to days not settled yet, see SetReapplyRules.
+type Service struct {
+	cfg          *settings.Config
+	strg         *storage.Storage
+	reapplyRules bool
+	today        func() time.Time
+}
+
+func NewService(cfg *settings.Config, strg *storage.Storage) *Service {
+	return &Service{cfg: cfg, strg: strg, today: Today}
+}
+
+// Today is the current local date at UTC midnight, like stored timer dates.
+func Today() time.Time {
+	d, _ := time.Parse(constnats.DateLayout, time.Now().Format(constnats.DateLayout))
+	return d
+}
+
+// SetReapplyRules makes the service evaluate settled days against the current
+// settings instead of their frozen rules, settling freezes the new ones.
+func (s *Service) SetReapplyRules(reapply bool) {
+	s.reapplyRules = reapply
+}
+
+// Preview evaluates the goals of a day without writing anything.
+func (s *Service) Preview(day time.Time) (DayResult, error) {
+	result := DayResult{Day: day}
+
+	dayType, ok, err := s.dayRules(day, &result)
+	if err != nil {
+		return result, err
+	}
+
+	// counted for skipped days too, they still earn XP
+	timers, err := s.strg.TimersRepo.GetTimersBetweenDates(day, day)
+	if err != nil {
+		return result, fmt.Errorf("timers between dates: %w", err)
+	}
+	total := time.Duration(0)
+	for _, t := range timers {
+		total += t.SecondsSpent
+	}
+	result.Minutes = int(total.Minutes())
+
+	result.TasksDone, err = s.strg.CompletionsRepo.CountByDate(day)
+	if err != nil {
+		return result, fmt.Errorf("count completions: %w", err)
+	}
+
+	if !ok {
+		result.Skipped = true
+		return result, nil
+	}
+	result.DayType = dayType.Name
+	result.Goals = len(dayType.FocusGoals) + len(dayType.TaskGoals)
+
+	result.Earned = make(models.WalletModel)
+	for _, goal := range dayType.FocusGoals {
+		if result.Minutes < goal.Minutes {
+			continue
+		}
+		if dayType.GoalMode == settings.GoalModeRestAfter &&
+			minutesBefore(timers, restAfterDeadline(day, goal.RestAfter)) < goal.Minutes {
+			result.LateGoals++
+			if goal.LateMedal != nil {
+				result.Earned[*goal.LateMedal] += goal.Count
+			}
+			continue
+		}
+		result.Earned[goal.Medal] += goal.Count
+		result.GoalsMet++
+	}
+	for _, goal := range dayType.TaskGoals {
+		if result.TasksDone >= goal.Tasks {
+			result.Earned[goal.Medal] += goal.Count
+			result.GoalsMet++
+		}
+	}
+	// a day that isn't over can still reach the penalty thresholds
+	if day.Before(s.today()) {
+		previewPenalties(dayType, &result)
+	}
+
+	result.Previous, err = s.strg.RewardsRepo.LoadByDate(day)
+	if err != nil {
+		return result, fmt.Errorf("load rewards: %w", err)
+	}
+	result.PreviousPenalty, err = s.strg.PenaltiesRepo.LoadByDate(day)
+	if err != nil {
+		return result, fmt.Errorf("load penalties: %w", err)
+	}
+	result.Delta = walletDiff(walletSum(result.Earned, result.Penalty), walletSum(result.Previous, result.PreviousPenalty))
+	return result, nil
+}
+
+// dayRules picks the day type a day is evaluated against: the rules frozen
+// when it was settled before, or the current settings for new days and with
+// reapplyRules. It returns false if there are neither.
+func (s *Service) dayRules(day time.Time, result *DayResult) (settings.DayType, bool, error) {
+	frozen, err := s.strg.RewardsRepo.LoadRules(day)
+	if err != nil {
+		return settings.DayType{}, false, fmt.Errorf("load rules: %w", err)
+	}
+
+	current, ok := s.cfg.Celendar[day.Weekday()]
+	var currentRules models.DayRulesModel
+	if ok {
+		currentRules, err = freezeRules(current)
+		if err != nil {
+			return settings.DayType{}, false, fmt.Errorf("freeze rules: %w", err)
+		}
+	}
+	result.RulesChanged = frozen != nil && (!ok || frozen.Hash != currentRules.Hash)
+
+	if frozen != nil && !s.reapplyRules {
+		dayType, err := thawRules(*frozen)
+		if err != nil {
+			return dayType, false, fmt.Errorf("rules frozen on %s: %w", day.Format(constnats.DateLayout), err)
+		}
+		result.rules = *frozen
+		return dayType, true, nil
+	}
+	result.rules = currentRules
+	return current, ok, nil
+}
+
+// PreviewDays evaluates the days like SettleDays without writing anything.
+func (s *Service) PreviewDays(ctx context.Context, days []time.Time) (Report, error) {
+	report := Report{Delta: make(models.WalletModel)}
+	streakDays, err := s.loadStreakDays()
+	if err != nil {
+		return report, err
+	}
+	wallet, err := s.strg.WalletRepo.Load()
+	if err != nil {
+		return report, fmt.Errorf("load wallet: %w", err)
+	}
+	for _, d := range days {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		result, err := s.Preview(d)
+		if err != nil {
+			return report, fmt.Errorf("preview %s: %w", d.Format(constnats.DateLayout), err)
+		}
+		if !result.Skipped {
+			s.floorPenalty(&result, wallet)
+			for medal, cnt := range result.Delta {
+				report.Delta[medal] += cnt
+			}
+		}
+		key := d.Format(constnats.DateLayout)
+		result.Status = dayStatus(result)
+		if result.Status == models.DayMissed && streakDays[key] == models.DayFrozen {
+			result.Status = models.DayFrozen
+		}
+		streakDays[key] = result.Status
+		report.Days = append(report.Days, result)
+	}
+
+	periods, err := s.previewClosedPeriods(days)
+	if err != nil {
+		return report, err
+	}
+	for _, period := range periods {
+		for medal, cnt := range period.Delta {
+			report.Delta[medal] += cnt
+		}
+	}
+	report.Periods = periods
+
+	if len(days) > 0 {
+		report.Streak, err = s.previewStreaks(streakDays)
+		if err != nil {
+			return report, err
+		}
+		for medal, cnt := range report.Streak.Delta {
+			report.Delta[medal] += cnt
+		}
+	}
+	return report, nil
+}
+
+// History returns the days from the first timer up to the day before today
+// that have timers, completed tasks, recorded rewards or penalties. Days
+// without any of them can't change the wallet, so they are left out.
+func (s *Service) History(today time.Time) ([]time.Time, error) {
+	first, err := s.strg.TimersRepo.GetFirstDate()
+	if err != nil {
+		return nil, fmt.Errorf("first timer date: %w", err)
+	}
+	yesterday := today.AddDate(0, 0, -1)
+	if first == nil || first.After(yesterday) {
+		return nil, nil
+	}
+
+	timerDays, err := s.strg.TimersRepo.GetDaysBetweenDates(*first, yesterday)
+	if err != nil {
+		return nil, fmt.Errorf("timer days: %w", err)
+	}
+	completionDays, err := s.strg.CompletionsRepo.DaysBetween(*first, yesterday)
+	if err != nil {
+		return nil, fmt.Errorf("completion days: %w", err)
+	}
+	rewardDays, err := s.strg.RewardsRepo.DaysBetween(*first, yesterday)
+	if err != nil {
+		return nil, fmt.Errorf("reward days: %w", err)
+	}
+	penaltyDays, err := s.strg.PenaltiesRepo.DaysBetween(*first, yesterday)
+	if err != nil {
+		return nil, fmt.Errorf("penalty days: %w", err)
+	}
+
+	days := slices.Concat(timerDays, completionDays, rewardDays, penaltyDays)
+	slices.SortFunc(days, time.Time.Compare)
+	return slices.CompactFunc(days, time.Time.Equal), nil
+}
+
+// Settle settles every day from from to to, both inclusive.
+func (s *Service) Settle(ctx context.Context, from, to time.Time) (Report, error) {
+	if to.Before(from) {
+		return Report{}, fmt.Errorf("%s is before %s", to.Format(constnats.DateLayout), from.Format(constnats.DateLayout))
+	}
+
+	var days []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return s.SettleDays(ctx, days)
+}
+
+// SettleDays replaces the rewards_daily records and XP events of the days
+// with what they earned now and updates the wallet once with the summed
+// change. The days levels were reached on are recorded again. Everything is
+// written in one transaction, a failed day leaves the storage as it was.
+func (s *Service) SettleDays(ctx context.Context, days []time.Time) (Report, error) {
+	var report Report
+	err := s.inTx(func(s *Service) error {
+		var err error
+		report, err = s.settleDays(ctx, days)
+		return err
+	})
+	return report, err
+}
+
+// inTx runs fn with a copy of the service whose storage writes in one
+// transaction.
+func (s *Service) inTx(fn func(s *Service) error) error {
+	return s.strg.InTx(func(tx *storage.Storage) error {
+		txService := *s
+		txService.strg = tx
+		return fn(&txService)
+	})
+}
+
+func (s *Service) settleDays(ctx context.Context, days []time.Time) (Report, error) {
+	report := Report{Delta: make(models.WalletModel)}
+	streakDays, err := s.loadStreakDays()
+	if err != nil {
+		return report, err
+	}
+	wallet, err := s.strg.WalletRepo.Load()
+	if err != nil {
+		return report, fmt.Errorf("load wallet: %w", err)
+	}
+	for _, d := range days {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		result, err := s.Preview(d)
+		if err != nil {
+			return report, fmt.Errorf("settle %s: %w", d.Format(constnats.DateLayout), err)
+		}
+		if !result.Skipped {
+			s.floorPenalty(&result, wallet)
+			// Replace per-day record to ensure idempotency
+			if err := s.strg.RewardsRepo.ReplaceForDate(d, result.Earned, result.rules); err != nil {
+				return report, fmt.Errorf("settle %s: replace rewards: %w", d.Format(constnats.DateLayout), err)
+			}
+			if err := s.strg.PenaltiesRepo.ReplaceForDate(d, result.Penalty, result.ShopBlockedUntil); err != nil {
+				return report, fmt.Errorf("settle %s: replace penalties: %w", d.Format(constnats.DateLayout), err)
+			}
+			for medal, cnt := range result.Delta {
+				report.Delta[medal] += cnt
+			}
+		}
+		result.Status, err = s.freezeStreak(streakDays, result)
+		if err != nil {
+			return report, fmt.Errorf("settle %s: %w", d.Format(constnats.DateLayout), err)
+		}
+		if err := s.strg.StreaksRepo.SaveStatus(d, result.Status); err != nil {
+			return report, fmt.Errorf("settle %s: %w", d.Format(constnats.DateLayout), err)
+		}
+		sources := daySources
+		if result.Skipped {
+			sources = skippedDaySources
+		}
+		if err := s.strg.XPRepo.ReplaceForDate(d, sources, s.dayXP(result)); err != nil {
+			return report, fmt.Errorf("settle %s: replace xp: %w", d.Format(constnats.DateLayout), err)
+		}
+		streakDays[d.Format(constnats.DateLayout)] = result.Status
+		report.Days = append(report.Days, result)
+	}
+
+	// weeks and months see the day rewards and rules written above
+	periods, err := s.previewClosedPeriods(days)
+	if err != nil {
+		return report, err
+	}
+	for _, period := range periods {
+		if err := s.strg.PeriodRewardsRepo.ReplaceForPeriod(period.Kind, period.Start, period.Earned, period.rules); err != nil {
+			return report, fmt.Errorf("settle %s of %s: replace rewards: %w", period.Kind, period.Start.Format(constnats.DateLayout), err)
+		}
+		xp := s.periodXP(period)
+		if err := s.strg.XPRepo.ReplaceForDate(xp.Day, []string{xp.Source}, []models.XPEventModel{xp}); err != nil {
+			return report, fmt.Errorf("settle %s of %s: replace xp: %w", period.Kind, period.Start.Format(constnats.DateLayout), err)
+		}
+		for medal, cnt := range period.Delta {
+			report.Delta[medal] += cnt
+		}
+	}
+	report.Periods = periods
+
+	if len(days) > 0 {
+		report.Streak, err = s.previewStreaks(streakDays)
+		if err != nil {
+			return report, err
+		}
+		if err := s.strg.StreaksRepo.ReplaceRewards(report.Streak.rewards); err != nil {
+			return report, fmt.Errorf("replace streak rewards: %w", err)
+		}
+		if err := s.strg.XPRepo.ReplaceSource(models.XPMilestones, s.milestoneXP(streakDays, report.Streak.rewards)); err != nil {
+			return report, fmt.Errorf("replace streak milestone xp: %w", err)
+		}
+		for medal, cnt := range report.Streak.Delta {
+			report.Delta[medal] += cnt
+		}
+
+		report.LevelUps, err = s.settleLevelUps()
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if err := s.applyWalletDelta(report.Delta); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// SplitClosed separates the days before today, which are over and can be
+// settled, from today and later ones that can still change.
+func (s *Service) SplitClosed(days []time.Time) (closed, open []time.Time) {
+	today := s.today()
+	for _, d := range days {
+		if d.Before(today) {
+			closed = append(closed, d)
+		} else {
+			open = append(open, d)
+		}
+	}
+	return closed, open
+}
+
+// SettleImported settles the days changed by an import that are over, the
+// ones it can't settle and today or later stay queued for fix-rewards
+// --pending and the next settlement.
+func (s *Service) SettleImported(ctx context.Context, days []time.Time) (Report, error) {
+	closed, open := s.SplitClosed(days)
+	if len(open) > 0 {
+		if err := s.strg.PendingDaysRepo.Add(open); err != nil {
+			return Report{}, fmt.Errorf("queue days: %w", err)
+		}
+	}
+	if len(closed) == 0 {
+		return Report{}, nil
+	}
+
+	var report Report
+	err := s.inTx(func(s *Service) error {
+		var err error
+		if report, err = s.settleDays(ctx, closed); err != nil {
+			return err
+		}
+		if err := s.strg.PendingDaysRepo.Remove(closed); err != nil {
+			return fmt.Errorf("remove settled days from the queue: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if queueErr := s.strg.PendingDaysRepo.Add(closed); queueErr != nil {
+			return Report{}, errors.Join(err, fmt.Errorf("queue days: %w", queueErr))
+		}
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// SettleCompleted settles the queued days and every day from the last
+// settlement up to the day before today. The first run only remembers that
+// day, so history is left to fix-rewards. Queued days from today on wait
+// until they are over. Days stored before XP existed get their XP first.
+func (s *Service) SettleCompleted(ctx context.Context, today time.Time) (Report, error) {
+	yesterday := today.AddDate(0, 0, -1)
+
+	lastSettled, err := s.strg.SettlementRepo.LastSettled()
+	if err != nil {
+		return Report{}, fmt.Errorf("last settled: %w", err)
+	}
+	if lastSettled == nil {
+		lastSettled = &yesterday
+	}
+
+	pending, err := s.strg.PendingDaysRepo.List()
+	if err != nil {
+		return Report{}, fmt.Errorf("pending days: %w", err)
+	}
+	var days []time.Time
+	for _, d := range pending {
+		if d.Before(today) {
+			days = append(days, d)
+		}
+	}
+	for d := lastSettled.AddDate(0, 0, 1); !d.After(yesterday); d = d.AddDate(0, 0, 1) {
+		if !slices.ContainsFunc(days, d.Equal) {
+			days = append(days, d)
+		}
+	}
+	slices.SortFunc(days, time.Time.Compare)
+
+	var report Report
+	err = s.inTx(func(s *Service) error {
+		backfilled, err := s.backfillXP()
+		if err != nil {
+			return err
+		}
+		if report, err = s.settleDays(ctx, days); err != nil {
+			return err
+		}
+		report.LevelUps = append(backfilled, report.LevelUps...)
+		if err := s.strg.PendingDaysRepo.Remove(days); err != nil {
+			return fmt.Errorf("remove pending days: %w", err)
+		}
+		if !lastSettled.After(yesterday) {
+			if err := s.strg.SettlementRepo.SaveLastSettled(yesterday); err != nil {
+				return fmt.Errorf("save last settled: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// applyWalletDelta adds the medals of delta to the wallet in one save.
+func (s *Service) applyWalletDelta(delta models.WalletModel) error {
+	if len(delta) == 0 {
+		return nil
+	}
+
+	wallet, err := s.strg.WalletRepo.Load()
+	if err != nil {
+		return fmt.Errorf("load wallet: %w", err)
+	}
+	if wallet == nil {
+		wallet = make(models.WalletModel)
+	}
+	for medal, cnt := range delta {
+		wallet[medal] += cnt
+	}
+	if err := s.strg.WalletRepo.Save(wallet); err != nil {
+		return fmt.Errorf("save wallet: %w", err)
+	}
+	return nil
+}
+
+// walletDiff returns a - b without the medals whose count didn't change.
+func walletDiff(a, b models.WalletModel) models.WalletModel {
+	diff := make(models.WalletModel)
+	for medal, cnt := range a {
+		diff[medal] += cnt
+	}
+	for medal, cnt := range b {
+		diff[medal] -= cnt
+	}
+	for medal, cnt := range diff {
+		if cnt == 0 {
+			delete(diff, medal)
+		}
+	}
+	return diff
+}
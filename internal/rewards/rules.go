@@ -0,0 +1,67 @@
+package rewards
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// freezeRules snapshots a day type of the settings, so a settled day can be
+// evaluated again with the same goals after the settings changed.
+func freezeRules(dayType settings.DayType) (models.DayRulesModel, error) {
+	rules, hash, err := snapshot(dayType)
+	if err != nil {
+		return models.DayRulesModel{}, fmt.Errorf("snapshot day type: %w", err)
+	}
+	return models.DayRulesModel{DayType: dayType.Name, Rules: rules, Hash: hash}, nil
+}
+
+// thawRules restores the day type a day was settled with.
+func thawRules(rules models.DayRulesModel) (settings.DayType, error) {
+	var dayType settings.DayType
+	if err := yaml.Unmarshal([]byte(rules.Rules), &dayType); err != nil {
+		return dayType, fmt.Errorf("unmarshal day type: %w", err)
+	}
+	if err := dayType.Validate(); err != nil {
+		return dayType, fmt.Errorf("validate day type: %w", err)
+	}
+	dayType.Name = rules.DayType
+	return dayType, nil
+}
+
+// freezePeriodGoals snapshots the weekly or monthly goals of the settings.
+func freezePeriodGoals(goals []settings.PeriodGoal) (models.PeriodRulesModel, error) {
+	rules, hash, err := snapshot(goals)
+	if err != nil {
+		return models.PeriodRulesModel{}, fmt.Errorf("snapshot period goals: %w", err)
+	}
+	return models.PeriodRulesModel{Rules: rules, Hash: hash}, nil
+}
+
+// thawPeriodGoals restores the goals a period was settled with.
+func thawPeriodGoals(rules models.PeriodRulesModel) ([]settings.PeriodGoal, error) {
+	var goals []settings.PeriodGoal
+	if err := yaml.Unmarshal([]byte(rules.Rules), &goals); err != nil {
+		return nil, fmt.Errorf("unmarshal period goals: %w", err)
+	}
+	for idx := range goals {
+		if err := goals[idx].Validate(); err != nil {
+			return nil, fmt.Errorf("validate period goal: %w", err)
+		}
+	}
+	return goals, nil
+}
+
+// snapshot returns the YAML of v and its hex encoded SHA-256.
+func snapshot(v any) (string, string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(data)
+	return string(data), hex.EncodeToString(sum[:]), nil
+}
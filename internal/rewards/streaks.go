@@ -0,0 +1,277 @@
+package rewards
+
+import (
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/storage"
+	"maps"
+	"slices"
+	"time"
+)
+
+// StreakResult describes the streaks of the settled days and the milestone
+// medals they earned.
+type StreakResult struct {
+	Current int
+	Best    int
+	// Freezes is the number of streak freezes left.
+	Freezes  int
+	Earned   models.WalletModel
+	Previous models.WalletModel
+	Delta    models.WalletModel
+
+	rewards []models.StreakRewardModel
+}
+
+// streak is a run of met days, frozen and neutral days in between are not counted.
+type streak struct {
+	start time.Time
+	days  int
+}
+
+// streakDays maps days, formatted with constnats.DateLayout, to their
+// streak status.
+type streakDays map[string]string
+
+func (s *Service) loadStreakDays() (streakDays, error) {
+	statuses, err := s.strg.StreaksRepo.Statuses()
+	if err != nil {
+		return nil, fmt.Errorf("streak statuses: %w", err)
+	}
+	days := make(streakDays, len(statuses))
+	for _, status := range statuses {
+		days[status.Day.Format(constnats.DateLayout)] = status.Status
+	}
+	return days, nil
+}
+
+// metDay returns the day a streak that started on start had its n-th met
+// day, the last stored day if the streak is shorter.
+func (days streakDays) metDay(start time.Time, n int) time.Time {
+	last, _ := time.Parse(constnats.DateLayout, slices.Max(slices.Collect(maps.Keys(days))))
+	d := start
+	for met := 0; d.Before(last); d = d.AddDate(0, 0, 1) {
+		if days[d.Format(constnats.DateLayout)] == models.DayMet {
+			met++
+		}
+		if met == n {
+			break
+		}
+	}
+	return d
+}
+
+// dayStatus is the streak status of an evaluated day before streak freezes.
+func dayStatus(result DayResult) string {
+	switch {
+	case result.Skipped || result.Goals == 0:
+		return models.DayNeutral
+	case result.GoalsMet > 0:
+		return models.DayMet
+	default:
+		return models.DayMissed
+	}
+}
+
+// walkStreaks returns the streaks of the days up to until and the length of
+// the one still running on until. Days that were never settled, e.g. skipped
+// by fix-rewards --all for having no data, are missed if the current
+// calendar has goals for them.
+func (s *Service) walkStreaks(days streakDays, until time.Time) ([]streak, int) {
+	if len(days) == 0 {
+		return nil, 0
+	}
+	first, _ := time.Parse(constnats.DateLayout, slices.Min(slices.Collect(maps.Keys(days))))
+
+	var streaks []streak
+	current := streak{}
+	for d := first; !d.After(until); d = d.AddDate(0, 0, 1) {
+		status, ok := days[d.Format(constnats.DateLayout)]
+		if !ok {
+			status = models.DayNeutral
+			if dayType, ok := s.cfg.Celendar[d.Weekday()]; ok && len(dayType.FocusGoals)+len(dayType.TaskGoals) > 0 {
+				status = models.DayMissed
+			}
+		}
+
+		switch status {
+		case models.DayMet:
+			if current.days == 0 {
+				current.start = d
+			}
+			current.days++
+		case models.DayMissed:
+			if current.days > 0 {
+				streaks = append(streaks, current)
+			}
+			current = streak{}
+		}
+	}
+	if current.days > 0 {
+		streaks = append(streaks, current)
+	}
+	return streaks, current.days
+}
+
+// freezeStreak decides the streak status of a day being settled. A missed day
+// settled for the first time uses a streak freeze if a streak was running
+// before it, settling it again never spends freezes bought later. A day that
+// used one and is met now gives it back.
+func (s *Service) freezeStreak(days streakDays, result DayResult) (string, error) {
+	key := result.Day.Format(constnats.DateLayout)
+	status := dayStatus(result)
+	prev := days[key]
+
+	switch {
+	case status == models.DayMissed && prev == models.DayFrozen:
+		status = models.DayFrozen
+	case status == models.DayMissed && prev != "":
+		// settled before without a freeze, the streak it had ended then
+	case status == models.DayMissed:
+		freezes, err := s.strg.StreaksRepo.Items(storage.ItemStreakFreeze)
+		if err != nil {
+			return "", fmt.Errorf("streak freezes: %w", err)
+		}
+		if _, running := s.walkStreaks(days, result.Day.AddDate(0, 0, -1)); freezes > 0 && running > 0 {
+			if err := s.strg.StreaksRepo.AddItems(storage.ItemStreakFreeze, -1); err != nil {
+				return "", fmt.Errorf("use streak freeze: %w", err)
+			}
+			status = models.DayFrozen
+		}
+	case prev == models.DayFrozen:
+		if err := s.strg.StreaksRepo.AddItems(storage.ItemStreakFreeze, 1); err != nil {
+			return "", fmt.Errorf("return streak freeze: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// previewStreaks evaluates the streak milestones of the days up to their
+// last one, like settlement would grant them.
+func (s *Service) previewStreaks(days streakDays) (StreakResult, error) {
+	result := StreakResult{Earned: make(models.WalletModel), Previous: make(models.WalletModel)}
+
+	stored, err := s.strg.StreaksRepo.Rewards()
+	if err != nil {
+		return result, fmt.Errorf("streak rewards: %w", err)
+	}
+	for _, reward := range stored {
+		result.Previous[reward.Medal] += reward.Count
+	}
+
+	result.Freezes, err = s.strg.StreaksRepo.Items(storage.ItemStreakFreeze)
+	if err != nil {
+		return result, fmt.Errorf("streak freezes: %w", err)
+	}
+
+	if len(days) > 0 {
+		last, _ := time.Parse(constnats.DateLayout, slices.Max(slices.Collect(maps.Keys(days))))
+		streaks, current := s.walkStreaks(days, last)
+		result.Current = current
+		for _, st := range streaks {
+			result.Best = max(result.Best, st.days)
+			result.rewards = append(result.rewards, s.streakRewards(st, stored)...)
+		}
+	}
+	for _, reward := range result.rewards {
+		result.Earned[reward.Medal] += reward.Count
+	}
+	result.Delta = walletDiff(result.Earned, result.Previous)
+	return result, nil
+}
+
+// streakRewards lists the milestones reached by a streak. Milestones granted
+// before keep their medals unless the rules are reapplied.
+func (s *Service) streakRewards(st streak, stored []models.StreakRewardModel) []models.StreakRewardModel {
+	var rewards []models.StreakRewardModel
+	for _, milestone := range s.cfg.Streaks.Milestones {
+		if milestone.Days > st.days {
+			continue
+		}
+		reward := models.StreakRewardModel{Start: st.start, Days: milestone.Days, Medal: milestone.Medal, Count: milestone.Count}
+		idx := slices.IndexFunc(stored, func(r models.StreakRewardModel) bool {
+			return r.Start.Equal(st.start) && r.Days == milestone.Days
+		})
+		if idx >= 0 && !s.reapplyRules {
+			reward = stored[idx]
+		}
+		rewards = append(rewards, reward)
+	}
+	if s.reapplyRules {
+		return rewards
+	}
+
+	// milestones removed from the settings stay granted
+	for _, reward := range stored {
+		if reward.Start.Equal(st.start) && reward.Days <= st.days &&
+			!slices.ContainsFunc(rewards, func(r models.StreakRewardModel) bool { return r.Days == reward.Days }) {
+			rewards = append(rewards, reward)
+		}
+	}
+	return rewards
+}
+
+// Streaks returns the current and the best streak up to today. Today only
+// counts once a daily goal is met, until then the streak of yesterday is
+// still running.
+func (s *Service) Streaks(today time.Time) (StreakResult, error) {
+	days, err := s.loadStreakDays()
+	if err != nil {
+		return StreakResult{}, err
+	}
+
+	todayResult, err := s.Preview(today)
+	if err != nil {
+		return StreakResult{}, fmt.Errorf("preview today: %w", err)
+	}
+	days[today.Format(constnats.DateLayout)] = models.DayNeutral
+	if dayStatus(todayResult) == models.DayMet {
+		days[today.Format(constnats.DateLayout)] = models.DayMet
+	}
+
+	return s.previewStreaks(days)
+}
+
+// ErrNotEnoughMedals is returned when the wallet can't pay a price.
+var ErrNotEnoughMedals = errors.New("rewards: not enough medals")
+
+// BuyStreakFreeze pays the freeze price of the settings from the wallet and
+// returns the number of freezes owned after the purchase.
+func (s *Service) BuyStreakFreeze() (int, error) {
+	price := s.cfg.Streaks.FreezePrice
+	if price == nil {
+		return 0, fmt.Errorf("no streaks.freezeprice in the settings")
+	}
+	if err := s.checkShop(); err != nil {
+		return 0, err
+	}
+
+	var freezes int
+	err := s.inTx(func(s *Service) error {
+		wallet, err := s.strg.WalletRepo.Load()
+		if err != nil {
+			return fmt.Errorf("load wallet: %w", err)
+		}
+		if wallet[price.Medal] < price.Count {
+			return fmt.Errorf("%w: a freeze costs %d %s, the wallet has %d", ErrNotEnoughMedals, price.Count, price.Medal, wallet[price.Medal])
+		}
+		if err := s.applyWalletDelta(models.WalletModel{price.Medal: -price.Count}); err != nil {
+			return err
+		}
+		if err := s.strg.StreaksRepo.AddItems(storage.ItemStreakFreeze, 1); err != nil {
+			return fmt.Errorf("add streak freeze: %w", err)
+		}
+
+		freezes, err = s.strg.StreaksRepo.Items(storage.ItemStreakFreeze)
+		if err != nil {
+			return fmt.Errorf("streak freezes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return freezes, nil
+}
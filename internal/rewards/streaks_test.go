@@ -0,0 +1,188 @@
+package rewards
+
+import (
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeStreaksRepo keeps the streak freezes in memory, other methods are not
+// used by the tested code.
+type fakeStreaksRepo struct {
+	storage.StreaksRepository
+	freezes int
+}
+
+func (r *fakeStreaksRepo) Items(item string) (int, error) {
+	return r.freezes, nil
+}
+
+func (r *fakeStreaksRepo) AddItems(item string, count int) error {
+	r.freezes += count
+	return nil
+}
+
+// weekdaysConfig has goals from Monday to Friday and none at the weekend.
+func weekdaysConfig() *settings.Config {
+	work := settings.DayType{Name: "work", FocusGoals: []settings.FocusDayGoal{{Minutes: 60, Count: 1, Medal: "bronze"}}}
+	cfg := &settings.Config{Celendar: make(map[time.Weekday]settings.DayType)}
+	for d := time.Monday; d <= time.Friday; d++ {
+		cfg.Celendar[d] = work
+	}
+	return cfg
+}
+
+func mustDay(t *testing.T, day string) time.Time {
+	t.Helper()
+	d, err := time.Parse(constnats.DateLayout, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestWalkStreaks(t *testing.T) {
+	type want struct {
+		start string
+		days  int
+	}
+
+	tests := []struct {
+		name    string
+		days    streakDays
+		until   string
+		streaks []want
+		running int
+	}{
+		{
+			name:  "no settled days",
+			days:  streakDays{},
+			until: "2025-11-12",
+		},
+		{
+			name:    "running streak",
+			days:    streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayMet, "2025-11-12": models.DayMet},
+			until:   "2025-11-12",
+			streaks: []want{{"2025-11-10", 3}},
+			running: 3,
+		},
+		{
+			name:    "missed day breaks the streak",
+			days:    streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayMissed, "2025-11-12": models.DayMet, "2025-11-13": models.DayMet},
+			until:   "2025-11-13",
+			streaks: []want{{"2025-11-10", 1}, {"2025-11-12", 2}},
+			running: 2,
+		},
+		{
+			name:    "frozen day keeps the streak without counting",
+			days:    streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayFrozen, "2025-11-12": models.DayMet},
+			until:   "2025-11-12",
+			streaks: []want{{"2025-11-10", 2}},
+			running: 2,
+		},
+		{
+			name:    "unsettled workday is missed",
+			days:    streakDays{"2025-11-10": models.DayMet, "2025-11-12": models.DayMet},
+			until:   "2025-11-12",
+			streaks: []want{{"2025-11-10", 1}, {"2025-11-12", 1}},
+			running: 1,
+		},
+		{
+			name:    "unsettled weekend is neutral",
+			days:    streakDays{"2025-11-14": models.DayMet, "2025-11-17": models.DayMet},
+			until:   "2025-11-17",
+			streaks: []want{{"2025-11-14", 2}},
+			running: 2,
+		},
+		{
+			name:    "days after until are not walked",
+			days:    streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayMet, "2025-11-12": models.DayMet},
+			until:   "2025-11-11",
+			streaks: []want{{"2025-11-10", 2}},
+			running: 2,
+		},
+		{
+			name:    "ended streak is not running",
+			days:    streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayMissed},
+			until:   "2025-11-11",
+			streaks: []want{{"2025-11-10", 1}},
+			running: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{cfg: weekdaysConfig()}
+			streaks, running := s.walkStreaks(tt.days, mustDay(t, tt.until))
+
+			got := make([]want, 0, len(streaks))
+			for _, st := range streaks {
+				got = append(got, want{st.start.Format(constnats.DateLayout), st.days})
+			}
+			if !slices.Equal(got, tt.streaks) {
+				t.Errorf("streaks = %v, want %v", got, tt.streaks)
+			}
+			if running != tt.running {
+				t.Errorf("running = %d, want %d", running, tt.running)
+			}
+		})
+	}
+}
+
+func TestFreezeStreak(t *testing.T) {
+	runningStreak := streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayMet, "2025-11-12": models.DayMet}
+	brokenStreak := streakDays{"2025-11-10": models.DayMet, "2025-11-11": models.DayMet, "2025-11-12": models.DayMissed}
+	met := DayResult{Goals: 1, GoalsMet: 1}
+	missed := DayResult{Goals: 1}
+
+	tests := []struct {
+		name        string
+		days        streakDays
+		prev        string
+		result      DayResult
+		freezes     int
+		want        string
+		wantFreezes int
+	}{
+		{name: "met day", days: runningStreak, result: met, freezes: 1, want: models.DayMet, wantFreezes: 1},
+		{name: "skipped day is neutral", days: runningStreak, result: DayResult{Skipped: true}, freezes: 1, want: models.DayNeutral, wantFreezes: 1},
+		{name: "day without goals is neutral", days: runningStreak, result: DayResult{}, freezes: 1, want: models.DayNeutral, wantFreezes: 1},
+		{name: "missed day uses a freeze", days: runningStreak, result: missed, freezes: 1, want: models.DayFrozen, wantFreezes: 0},
+		{name: "missed day without freezes", days: runningStreak, result: missed, freezes: 0, want: models.DayMissed, wantFreezes: 0},
+		{name: "missed day without a running streak", days: brokenStreak, result: missed, freezes: 1, want: models.DayMissed, wantFreezes: 1},
+		{name: "missed day settled again keeps its freezes", days: runningStreak, prev: models.DayMissed, result: missed, freezes: 1, want: models.DayMissed, wantFreezes: 1},
+		{name: "met day missed now keeps its freezes", days: runningStreak, prev: models.DayMet, result: missed, freezes: 1, want: models.DayMissed, wantFreezes: 1},
+		{name: "frozen day settled again stays frozen", days: runningStreak, prev: models.DayFrozen, result: missed, freezes: 0, want: models.DayFrozen, wantFreezes: 0},
+		{name: "frozen day met now gives the freeze back", days: runningStreak, prev: models.DayFrozen, result: met, freezes: 0, want: models.DayMet, wantFreezes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeStreaksRepo{freezes: tt.freezes}
+			s := &Service{cfg: weekdaysConfig(), strg: &storage.Storage{StreaksRepo: repo}}
+
+			days := streakDays{}
+			for day, status := range tt.days {
+				days[day] = status
+			}
+			result := tt.result
+			result.Day = mustDay(t, "2025-11-13")
+			if tt.prev != "" {
+				days["2025-11-13"] = tt.prev
+			}
+
+			got, err := s.freezeStreak(days, result)
+			if err != nil {
+				t.Fatalf("freezeStreak() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("freezeStreak() = %q, want %q", got, tt.want)
+			}
+			if repo.freezes != tt.wantFreezes {
+				t.Errorf("freezes = %d, want %d", repo.freezes, tt.wantFreezes)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package rewards
+
+import (
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"slices"
+	"time"
+)
+
+// XPResult is the XP earned so far and the level it reached.
+type XPResult struct {
+	Total int
+	// Today is the part of Total earned today, which isn't settled yet.
+	Today int
+	Level settings.LevelDef
+	// Next is the level after Level, nil if Level is the last one.
+	Next *settings.LevelDef
+	// LevelUps are the levels reached so far with their dates.
+	LevelUps []models.LevelUpModel
+}
+
+// daySources are the XP sources replaced when a day is settled. Days without
+// a day type keep the medals they were settled with, so their medal XP stays.
+var (
+	daySources        = []string{models.XPMinutes, models.XPTasks, models.XPMedals, models.XPStreak}
+	skippedDaySources = []string{models.XPMinutes, models.XPTasks, models.XPStreak}
+)
+
+// dayXP lists the XP a day earned with the weights of the settings.
+func (s *Service) dayXP(result DayResult) []models.XPEventModel {
+	weights := s.cfg.XP
+	events := []models.XPEventModel{
+		{Day: result.Day, Source: models.XPMinutes, XP: result.Minutes * weights.MinuteXP},
+		{Day: result.Day, Source: models.XPTasks, XP: result.TasksDone * weights.Task},
+		{Day: result.Day, Source: models.XPMedals, XP: s.medalXP(result.Earned)},
+	}
+	if result.Status == models.DayMet {
+		events = append(events, models.XPEventModel{Day: result.Day, Source: models.XPStreak, XP: weights.StreakDay})
+	}
+	return events
+}
+
+// medalXP is the XP of the medals in wallet, penalties don't take XP.
+func (s *Service) medalXP(wallet models.WalletModel) int {
+	xp := 0
+	for medal, cnt := range wallet {
+		if cnt > 0 {
+			xp += cnt * s.cfg.XP.Medals[medal]
+		}
+	}
+	return xp
+}
+
+// periodXP is the XP event of the medals a closed week or month earned.
+func (s *Service) periodXP(period PeriodResult) models.XPEventModel {
+	source := models.XPWeek
+	if period.Kind == PeriodMonth {
+		source = models.XPMonth
+	}
+	return models.XPEventModel{Day: period.End, Source: source, XP: s.medalXP(period.Earned)}
+}
+
+// milestoneXP sums the XP of the streak milestones per day they were reached.
+func (s *Service) milestoneXP(days streakDays, rewards []models.StreakRewardModel) []models.XPEventModel {
+	var events []models.XPEventModel
+	for _, reward := range rewards {
+		day := days.metDay(reward.Start, reward.Days)
+		xp := s.medalXP(models.WalletModel{reward.Medal: reward.Count})
+		if n := len(events); n > 0 && events[n-1].Day.Equal(day) {
+			events[n-1].XP += xp
+			continue
+		}
+		events = append(events, models.XPEventModel{Day: day, Source: models.XPMilestones, XP: xp})
+	}
+	return events
+}
+
+// backfillXP records the XP of the days stored before XP existed and returns
+// the levels it reached. Weeks and months earn their XP once they are fixed
+// again.
+func (s *Service) backfillXP() ([]models.LevelUpModel, error) {
+	days, err := s.strg.XPRepo.BackfillDays()
+	if err != nil {
+		return nil, fmt.Errorf("xp backfill days: %w", err)
+	}
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	streakDays, err := s.loadStreakDays()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range days {
+		events, err := s.backfillDayXP(d, streakDays)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.strg.XPRepo.ReplaceForDate(d, daySources, events); err != nil {
+			return nil, fmt.Errorf("backfill xp of %s: %w", d.Format(constnats.DateLayout), err)
+		}
+	}
+
+	if len(streakDays) > 0 {
+		streakRewards, err := s.strg.StreaksRepo.Rewards()
+		if err != nil {
+			return nil, fmt.Errorf("streak rewards: %w", err)
+		}
+		if err := s.strg.XPRepo.ReplaceSource(models.XPMilestones, s.milestoneXP(streakDays, streakRewards)); err != nil {
+			return nil, fmt.Errorf("backfill streak milestone xp: %w", err)
+		}
+	}
+	if err := s.strg.XPRepo.RemoveBackfillDays(days); err != nil {
+		return nil, fmt.Errorf("remove xp backfill days: %w", err)
+	}
+	return s.settleLevelUps()
+}
+
+// backfillDayXP is the XP of a day recorded before XP existed, its medals
+// are the ones it was settled with.
+func (s *Service) backfillDayXP(d time.Time, streakDays streakDays) ([]models.XPEventModel, error) {
+	result, err := s.Preview(d)
+	if err != nil {
+		return nil, fmt.Errorf("backfill xp of %s: %w", d.Format(constnats.DateLayout), err)
+	}
+	result.Earned, err = s.strg.RewardsRepo.LoadByDate(d)
+	if err != nil {
+		return nil, fmt.Errorf("backfill xp of %s: load rewards: %w", d.Format(constnats.DateLayout), err)
+	}
+	result.Status = streakDays[d.Format(constnats.DateLayout)]
+	return s.dayXP(result), nil
+}
+
+// settleLevelUps records the day every level was reached with the stored XP
+// events and returns the levels reached for the first time.
+func (s *Service) settleLevelUps() ([]models.LevelUpModel, error) {
+	events, err := s.strg.XPRepo.Events()
+	if err != nil {
+		return nil, fmt.Errorf("xp events: %w", err)
+	}
+	previous, err := s.strg.XPRepo.LevelUps()
+	if err != nil {
+		return nil, fmt.Errorf("level ups: %w", err)
+	}
+
+	levelUps := levelUps(s.cfg.Levels, events)
+	if err := s.strg.XPRepo.ReplaceLevelUps(levelUps); err != nil {
+		return nil, fmt.Errorf("replace level ups: %w", err)
+	}
+
+	var reached []models.LevelUpModel
+	for _, levelUp := range levelUps {
+		if !slices.ContainsFunc(previous, func(p models.LevelUpModel) bool { return p.Level == levelUp.Level }) {
+			reached = append(reached, levelUp)
+		}
+	}
+	return reached, nil
+}
+
+// levelUps returns the days the levels above 0 were reached, events have to
+// be ordered by day.
+func levelUps(levels []settings.LevelDef, events []models.XPEventModel) []models.LevelUpModel {
+	var levelUps []models.LevelUpModel
+	total, next := 0, 0
+	for _, event := range events {
+		total += event.XP
+		for ; next < len(levels) && total >= levels[next].Threshold; next++ {
+			if levels[next].Lvl > 0 {
+				levelUps = append(levelUps, models.LevelUpModel{Level: levels[next].Lvl, Name: levels[next].Name, Day: event.Day})
+			}
+		}
+	}
+	return levelUps
+}
+
+// levelFor returns the highest level xp reaches and the one after it.
+func levelFor(levels []settings.LevelDef, xp int) (settings.LevelDef, *settings.LevelDef) {
+	level := settings.LevelDef{}
+	for i := range levels {
+		if xp < levels[i].Threshold {
+			return level, &levels[i]
+		}
+		level = levels[i]
+	}
+	return level, nil
+}
+
+// XP returns the XP of the settled days and of today so far with the level
+// it reaches. Today counts like it would be settled now, days stored before XP
+// existed like they will be backfilled.
+func (s *Service) XP(today time.Time) (XPResult, error) {
+	var result XPResult
+
+	events, err := s.strg.XPRepo.Events()
+	if err != nil {
+		return result, fmt.Errorf("xp events: %w", err)
+	}
+	recorded := make(map[string]bool)
+	for _, event := range events {
+		recorded[event.Day.Format(constnats.DateLayout)] = true
+		if !event.Day.Equal(today) {
+			result.Total += event.XP
+		}
+	}
+
+	backfillDays, err := s.strg.XPRepo.BackfillDays()
+	if err != nil {
+		return result, fmt.Errorf("xp backfill days: %w", err)
+	}
+	if len(backfillDays) > 0 {
+		streakDays, err := s.loadStreakDays()
+		if err != nil {
+			return result, err
+		}
+		for _, d := range backfillDays {
+			// days settled since count with their events, today with the preview below
+			if recorded[d.Format(constnats.DateLayout)] || d.Equal(today) {
+				continue
+			}
+			dayEvents, err := s.backfillDayXP(d, streakDays)
+			if err != nil {
+				return result, err
+			}
+			for _, event := range dayEvents {
+				result.Total += event.XP
+			}
+		}
+	}
+
+	todayResult, err := s.Preview(today)
+	if err != nil {
+		return result, fmt.Errorf("preview today: %w", err)
+	}
+	todayResult.Status = dayStatus(todayResult)
+	for _, event := range s.dayXP(todayResult) {
+		result.Today += event.XP
+	}
+	result.Total += result.Today
+
+	result.Level, result.Next = levelFor(s.cfg.Levels, result.Total)
+	levelUps, err := s.strg.XPRepo.LevelUps()
+	if err != nil {
+		return result, fmt.Errorf("level ups: %w", err)
+	}
+	// levels whose threshold was raised after they were recorded aren't reached anymore
+	for _, levelUp := range levelUps {
+		if levelUp.Level <= result.Level.Lvl {
+			result.LevelUps = append(result.LevelUps, levelUp)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,205 @@
+package rewards
+
+import (
+	"context"
+	"database/sql"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"gomificator/internal/settings"
+	"gomificator/internal/storage"
+	"gomificator/internal/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+var testLevels = []settings.LevelDef{
+	{Lvl: 0, Name: "Novice", Threshold: 0},
+	{Lvl: 1, Name: "Apprentice", Threshold: 100},
+	{Lvl: 2, Name: "Journeyman", Threshold: 250},
+	{Lvl: 3, Name: "Master", Threshold: 500},
+}
+
+func TestLevelFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		levels   []settings.LevelDef
+		xp       int
+		wantLvl  int
+		wantNext int // -1 when there is no next level
+	}{
+		{name: "no xp", levels: testLevels, xp: 0, wantLvl: 0, wantNext: 1},
+		{name: "below the first threshold", levels: testLevels, xp: 99, wantLvl: 0, wantNext: 1},
+		{name: "exactly on a threshold", levels: testLevels, xp: 100, wantLvl: 1, wantNext: 2},
+		{name: "between thresholds", levels: testLevels, xp: 300, wantLvl: 2, wantNext: 3},
+		{name: "last level", levels: testLevels, xp: 500, wantLvl: 3, wantNext: -1},
+		{name: "beyond the last level", levels: testLevels, xp: 10000, wantLvl: 3, wantNext: -1},
+		{name: "no levels", levels: nil, xp: 50, wantLvl: 0, wantNext: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, next := levelFor(tt.levels, tt.xp)
+			if level.Lvl != tt.wantLvl {
+				t.Errorf("level = %d, want %d", level.Lvl, tt.wantLvl)
+			}
+			gotNext := -1
+			if next != nil {
+				gotNext = next.Lvl
+			}
+			if gotNext != tt.wantNext {
+				t.Errorf("next = %d, want %d", gotNext, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestLevelUps(t *testing.T) {
+	type levelUp struct {
+		level int
+		day   string
+	}
+
+	tests := []struct {
+		name   string
+		events []models.XPEventModel
+		want   []levelUp
+	}{
+		{
+			name: "no events",
+		},
+		{
+			name: "below the first level",
+			events: []models.XPEventModel{
+				{Day: mustDayValue("2025-11-10"), Source: models.XPMinutes, XP: 60},
+			},
+		},
+		{
+			name: "levels reached on the day the threshold is crossed",
+			events: []models.XPEventModel{
+				{Day: mustDayValue("2025-11-10"), Source: models.XPMinutes, XP: 60},
+				{Day: mustDayValue("2025-11-11"), Source: models.XPMinutes, XP: 60},
+				{Day: mustDayValue("2025-11-12"), Source: models.XPMedals, XP: 130},
+			},
+			want: []levelUp{{1, "2025-11-11"}, {2, "2025-11-12"}},
+		},
+		{
+			name: "several levels on one day",
+			events: []models.XPEventModel{
+				{Day: mustDayValue("2025-11-10"), Source: models.XPMinutes, XP: 400},
+				{Day: mustDayValue("2025-11-10"), Source: models.XPTasks, XP: 100},
+			},
+			want: []levelUp{{1, "2025-11-10"}, {2, "2025-11-10"}, {3, "2025-11-10"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]levelUp, 0, len(tt.want))
+			for _, l := range levelUps(testLevels, tt.events) {
+				got = append(got, levelUp{l.Level, l.Day.Format(constnats.DateLayout)})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("levelUps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// baselineStorage opens a database created before XP existed with 60 focus
+// minutes on every given day and migrates it to the current schema.
+func baselineStorage(t *testing.T, days []string) *storage.Storage {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir, err := utils.EnsureAppDataLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "data.db")+"?mode=rwc&_fk=1")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatalf("set dialect: %v", err)
+	}
+	goose.SetBaseFS(os.DirFS("../storage"))
+	if err := goose.UpTo(db, "migrations", 20251023163553); err != nil {
+		t.Fatalf("baseline migration: %v", err)
+	}
+	for _, day := range days {
+		_, err := db.Exec("INSERT INTO timers (external_id, fixed_at, seconds_spent, name) VALUES (?, ?, ?, ?)",
+			"SP:"+day, day, 3600, "focus")
+		if err != nil {
+			t.Fatalf("insert timer: %v", err)
+		}
+	}
+
+	strg, err := storage.NewSqlliteStorage()
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	if err := storage.MigrateDb(strg); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	return strg
+}
+
+func TestXPAfterUpgrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		days      []string
+		wantXP    int
+		wantLevel int
+	}{
+		{name: "no history", wantXP: 0, wantLevel: 0},
+		{name: "minutes of every stored day", days: []string{"2025-11-03", "2025-11-04", "2025-11-08"}, wantXP: 180, wantLevel: 1},
+		{name: "enough for several levels", days: []string{"2025-10-01", "2025-10-02", "2025-10-03", "2025-10-04", "2025-10-05"}, wantXP: 300, wantLevel: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := weekdaysConfig()
+			cfg.Levels = testLevels
+			cfg.XP.MinuteXP = 1
+			s := NewService(cfg, baselineStorage(t, tt.days))
+			today := mustDay(t, "2025-11-20")
+			s.today = func() time.Time { return today }
+
+			// levels are read before and after the first settlement
+			for _, step := range []string{"upgraded", "settled"} {
+				if step == "settled" {
+					report, err := s.SettleCompleted(context.Background(), today)
+					if err != nil {
+						t.Fatalf("SettleCompleted() error = %v", err)
+					}
+					if len(report.LevelUps) != tt.wantLevel {
+						t.Errorf("%d levels reached by the settlement, want %d", len(report.LevelUps), tt.wantLevel)
+					}
+				}
+				xp, err := s.XP(today)
+				if err != nil {
+					t.Fatalf("XP() error = %v", err)
+				}
+				if xp.Total != tt.wantXP {
+					t.Errorf("%s: XP = %d, want %d", step, xp.Total, tt.wantXP)
+				}
+				if xp.Level.Lvl != tt.wantLevel {
+					t.Errorf("%s: level = %d, want %d", step, xp.Level.Lvl, tt.wantLevel)
+				}
+			}
+		})
+	}
+}
+
+// mustDayValue is mustDay for test tables built outside of a test.
+func mustDayValue(day string) time.Time {
+	d, err := time.Parse(constnats.DateLayout, day)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
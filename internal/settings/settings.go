@@ -6,6 +6,7 @@ import (
 	"gomificator/internal/utils"
 	"os"
 	"path/filepath"
+	"regexp"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -31,6 +32,14 @@ type Config struct {
 	AlwaysRestAfter    time.Time                `yaml:"-"`
 	AutoImport         AutoImportConfig         `yaml:"autoimport"`
 	Levels             []LevelDef               `yaml:"levels"`
+	XP                 XPConfig                 `yaml:"xp"`
+	WeeklyGoals        []PeriodGoal             `yaml:"weeklygoals"`
+	MonthlyGoals       []PeriodGoal             `yaml:"monthlygoals"`
+	Streaks            StreaksConfig            `yaml:"streaks"`
+	// PenaltyFloor is the count of every medal kind penalties don't take the
+	// wallet below.
+	PenaltyFloor int             `yaml:"penaltyfloor"`
+	Importers    ImportersConfig `yaml:"importers"`
 }
 
 func (c *Config) Validate() error {
@@ -70,6 +79,33 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("autoimport: %w", err)
 	}
 
+	if err := c.Importers.Validate(); err != nil {
+		return fmt.Errorf("importers: %w", err)
+	}
+
+	for idx := range c.WeeklyGoals {
+		if err := c.WeeklyGoals[idx].Validate(); err != nil {
+			return fmt.Errorf("weekly goals: %w", err)
+		}
+	}
+	for idx := range c.MonthlyGoals {
+		if err := c.MonthlyGoals[idx].Validate(); err != nil {
+			return fmt.Errorf("monthly goals: %w", err)
+		}
+	}
+
+	if err := c.Streaks.Validate(); err != nil {
+		return fmt.Errorf("streaks: %w", err)
+	}
+
+	if c.PenaltyFloor < 0 {
+		return fmt.Errorf("penaltyfloor must not be negative")
+	}
+
+	if err := c.XP.Validate(); err != nil {
+		return fmt.Errorf("xp: %w", err)
+	}
+
 	// Validate Levels definitions if provided
 	if err := validateLevels(c.Levels); err != nil {
 		return fmt.Errorf("levels: %w", err)
@@ -93,16 +129,71 @@ func (c *Config) Validate() error {
 // }
 
 type DayType struct {
-	Name       string         `yaml:"-"`
+	Name string `yaml:"-"`
+	// GoalMode decides when focus goals count, GoalModeTotal if empty.
+	GoalMode   string         `yaml:"goalmode,omitempty" validate:"omitempty,oneof=total restafter"`
 	FocusGoals []FocusDayGoal `yaml:"focusgoals"`
+	TaskGoals  []TaskDayGoal  `yaml:"taskgoals"`
+	Penalties  []DayPenalty   `yaml:"penalties,omitempty"`
 }
 
+// When focus goals of a day type count.
+const (
+	// GoalModeTotal counts a goal once the minutes of the whole day reach it.
+	GoalModeTotal = "total"
+	// GoalModeRestAfter counts only the minutes tracked before the restafter
+	// time of the goal. Goals reached later earn their latemedal, if any.
+	GoalModeRestAfter = "restafter"
+)
+
+// LevelDef is reached once the XP earned so far is at least Threshold.
 type LevelDef struct {
 	Lvl       int    `yaml:"lvl" validate:"gte=0"`
 	Name      string `yaml:"name" validate:"required"`
 	Threshold int    `yaml:"threshold" validate:"gte=0"`
 }
 
+// XPConfig weighs what earns experience points. Without it a focus minute is
+// worth 1 XP and nothing else counts, so levels defined in minutes still work.
+type XPConfig struct {
+	// Minute is the XP of a focus minute, 1 if not set.
+	Minute   *int `yaml:"minute" validate:"omitempty,gte=0"`
+	MinuteXP int  `yaml:"-"`
+	// Task is the XP of a completed task.
+	Task int `yaml:"task" validate:"gte=0"`
+	// StreakDay is the XP of every met day of a streak.
+	StreakDay int `yaml:"streakday" validate:"gte=0"`
+	// Medals is the XP of a medal earned by a goal or a streak milestone.
+	MedalsRaw map[string]int          `yaml:"medals"`
+	Medals    map[constnats.Medal]int `yaml:"-"`
+}
+
+func (x *XPConfig) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(x); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+
+	x.MinuteXP = 1
+	if x.Minute != nil {
+		x.MinuteXP = *x.Minute
+	}
+
+	x.Medals = make(map[constnats.Medal]int, len(x.MedalsRaw))
+	for medalStr, xp := range x.MedalsRaw {
+		medal, err := constnats.LoadMedal(medalStr)
+		if err != nil {
+			return fmt.Errorf("medals: %w", err)
+		}
+		if xp < 0 {
+			return fmt.Errorf("medals: %s must not be negative", medal)
+		}
+		x.Medals[medal] = xp
+	}
+	return nil
+}
+
 func validateLevels(levels []LevelDef) error {
 	if len(levels) == 0 {
 		return nil
@@ -138,6 +229,16 @@ func (d *DayType) Validate() error {
 			errs = append(errs, fmt.Errorf("focus goals: %w", err))
 		}
 	}
+	for idx := range d.TaskGoals {
+		if err := d.TaskGoals[idx].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("task goals: %w", err))
+		}
+	}
+	for idx := range d.Penalties {
+		if err := d.Penalties[idx].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("penalties: %w", err))
+		}
+	}
 	if len(errs) > 0 {
 		return fmt.Errorf("day type validation errors: %v", errs)
 	}
@@ -154,6 +255,11 @@ type FocusDayGoal struct {
 
 	MedalStr string          `yaml:"medal" validate:"required"`
 	Medal    constnats.Medal `yaml:"-"`
+
+	// LateMedal is earned instead of Medal when the goal was reached only
+	// after RestAfter in GoalModeRestAfter, nothing is earned if it's empty.
+	LateMedalStr string           `yaml:"latemedal,omitempty"`
+	LateMedal    *constnats.Medal `yaml:"-"`
 }
 
 func (f *FocusDayGoal) Validate() error {
@@ -169,6 +275,17 @@ func (f *FocusDayGoal) Validate() error {
 	}
 	f.Medal = medal
 
+	if f.LateMedalStr != "" {
+		lateMedal, err := constnats.LoadMedal(f.LateMedalStr)
+		if err != nil {
+			return fmt.Errorf("load late medal: %w", err)
+		}
+		if lateMedal.Rank() >= medal.Rank() {
+			return fmt.Errorf("late medal %s must be lower than %s", lateMedal, medal)
+		}
+		f.LateMedal = &lateMedal
+	}
+
 	time, err := time.Parse(constnats.TimeLayout, f.RestAfterStr)
 	if err != nil {
 		return fmt.Errorf("parse rest after time: %w", err)
@@ -178,6 +295,175 @@ func (f *FocusDayGoal) Validate() error {
 	return nil
 }
 
+// TaskDayGoal rewards a number of tasks completed during a day.
+type TaskDayGoal struct {
+	Tasks int `yaml:"tasks" validate:"gte=1"`
+	Count int `yaml:"count" validate:"gte=0,lte=1440"`
+
+	MedalStr string          `yaml:"medal" validate:"required"`
+	Medal    constnats.Medal `yaml:"-"`
+}
+
+func (t *TaskDayGoal) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(t); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+
+	medal, err := constnats.LoadMedal(t.MedalStr)
+	if err != nil {
+		return fmt.Errorf("load medal: %w", err)
+	}
+	t.Medal = medal
+
+	return nil
+}
+
+// DayPenalty is applied to a day that falls short of any of its targets that
+// isn't zero. It takes medals from the wallet, blocks shop purchases until
+// the end of the day after, or both.
+type DayPenalty struct {
+	// Minutes and Tasks are the least a day has to reach to avoid the penalty.
+	Minutes int `yaml:"minutes" validate:"gte=0,lte=1440"`
+	Tasks   int `yaml:"tasks" validate:"gte=0"`
+	Count   int `yaml:"count,omitempty" validate:"gte=0,lte=1440"`
+
+	MedalStr string           `yaml:"medal,omitempty"`
+	Medal    *constnats.Medal `yaml:"-"`
+
+	BlockShop bool `yaml:"blockshop,omitempty"`
+}
+
+func (p *DayPenalty) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(p); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+	if p.Minutes == 0 && p.Tasks == 0 {
+		return fmt.Errorf("either minutes or tasks is required")
+	}
+	if p.MedalStr == "" && !p.BlockShop {
+		return fmt.Errorf("either medal or blockshop is required")
+	}
+
+	if p.MedalStr != "" {
+		medal, err := constnats.LoadMedal(p.MedalStr)
+		if err != nil {
+			return fmt.Errorf("load medal: %w", err)
+		}
+		if p.Count == 0 {
+			return fmt.Errorf("count is required with medal")
+		}
+		p.Medal = &medal
+	}
+
+	return nil
+}
+
+// PeriodGoal rewards a week or a month once it is over. Every target that
+// isn't zero has to be reached.
+type PeriodGoal struct {
+	Minutes int `yaml:"minutes" validate:"gte=0"`
+	// Days counts the days of the period on which a daily goal was met.
+	Days  int `yaml:"days" validate:"gte=0,lte=31"`
+	Count int `yaml:"count" validate:"gte=0,lte=1440"`
+
+	MedalStr string          `yaml:"medal" validate:"required"`
+	Medal    constnats.Medal `yaml:"-"`
+}
+
+func (p *PeriodGoal) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(p); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+	if p.Minutes == 0 && p.Days == 0 {
+		return fmt.Errorf("either minutes or days is required")
+	}
+
+	medal, err := constnats.LoadMedal(p.MedalStr)
+	if err != nil {
+		return fmt.Errorf("load medal: %w", err)
+	}
+	p.Medal = medal
+
+	return nil
+}
+
+// StreaksConfig rewards runs of consecutive days on which a daily goal was met.
+type StreaksConfig struct {
+	Milestones []StreakMilestone `yaml:"milestones"`
+	// FreezePrice is what a streak freeze costs, freezes can't be bought if
+	// it's not set. A freeze keeps the streak over one missed day.
+	FreezePrice *MedalPrice `yaml:"freezeprice"`
+}
+
+func (s *StreaksConfig) Validate() error {
+	for idx := range s.Milestones {
+		if err := s.Milestones[idx].Validate(); err != nil {
+			return fmt.Errorf("milestones: %w", err)
+		}
+	}
+	if s.FreezePrice != nil {
+		if err := s.FreezePrice.Validate(); err != nil {
+			return fmt.Errorf("freeze price: %w", err)
+		}
+	}
+	return nil
+}
+
+// StreakMilestone is earned once by every streak that lasts Days days.
+type StreakMilestone struct {
+	Days  int `yaml:"days" validate:"gte=1"`
+	Count int `yaml:"count" validate:"gte=0,lte=1440"`
+
+	MedalStr string          `yaml:"medal" validate:"required"`
+	Medal    constnats.Medal `yaml:"-"`
+}
+
+func (m *StreakMilestone) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(m); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+
+	medal, err := constnats.LoadMedal(m.MedalStr)
+	if err != nil {
+		return fmt.Errorf("load medal: %w", err)
+	}
+	m.Medal = medal
+
+	return nil
+}
+
+// MedalPrice is a number of medals of one kind.
+type MedalPrice struct {
+	Count int `yaml:"count" validate:"gte=1"`
+
+	MedalStr string          `yaml:"medal" validate:"required"`
+	Medal    constnats.Medal `yaml:"-"`
+}
+
+func (p *MedalPrice) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(p); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+
+	medal, err := constnats.LoadMedal(p.MedalStr)
+	if err != nil {
+		return fmt.Errorf("load medal: %w", err)
+	}
+	p.Medal = medal
+
+	return nil
+}
+
 func newDefaultConfig() *Config {
 	return &Config{
 		// PomoConfig: PomodoroConfig{
@@ -189,26 +475,245 @@ func newDefaultConfig() *Config {
 	}
 }
 
+// AutoImportConfig lists the directories followed by autoimport. The
+// top-level Path, Source and Watch describe a single source and are kept for
+// older settings files, Every is also the default interval of Sources.
 type AutoImportConfig struct {
-	EveryStr string        `yaml:"every" validate:"required"`
+	EveryStr string        `yaml:"every"`
+	Every    time.Duration `yaml:"-"`
+	Path     string        `yaml:"path"`
+	// Source is the importer used for files found in Path, spbackup if empty.
+	// It is also the default importer of Sources.
+	Source string `yaml:"source"`
+	// Watch imports as soon as files in Path change, Every stays as a fallback.
+	Watch bool `yaml:"watch"`
+
+	Sources []AutoImportSource `yaml:"sources,omitempty"`
+}
+
+// Which files of a source directory autoimport picks up.
+const (
+	// AutoImportNewest imports only the most recently modified file, e.g.
+	// the latest of rotating full backups.
+	AutoImportNewest = "newest"
+	// AutoImportAll imports every changed file, e.g. a drop folder of exports.
+	AutoImportAll = "all"
+)
+
+const (
+	defaultAutoImportSource = "spbackup"
+	defaultAutoImportGlob   = "*.json"
+)
+
+// AutoImportSource is one directory followed by autoimport.
+type AutoImportSource struct {
+	// Name identifies the source in logs, Path if empty.
+	Name string `yaml:"name"`
+	Path string `yaml:"path" validate:"required"`
+	// Glob selects files in Path, *.json if empty.
+	Glob   string `yaml:"glob"`
+	Source string `yaml:"source"`
+	Policy string `yaml:"policy" validate:"omitempty,oneof=newest all"`
+	Watch  bool   `yaml:"watch"`
+
+	EveryStr string        `yaml:"every"`
 	Every    time.Duration `yaml:"-"`
-	Path     string        `yaml:"path" validate:"required"`
 }
 
 func (a *AutoImportConfig) Validate() error {
+	if a.EveryStr != "" {
+		d, err := parseAutoImportEvery(a.EveryStr)
+		if err != nil {
+			return err
+		}
+		a.Every = d
+	}
+
+	if a.Source == "" {
+		a.Source = defaultAutoImportSource
+	}
+
+	if len(a.Sources) == 0 {
+		if a.Path == "" {
+			return fmt.Errorf("either path or sources is required")
+		}
+		a.Sources = []AutoImportSource{{
+			Path:   a.Path,
+			Source: a.Source,
+			Watch:  a.Watch,
+		}}
+	}
+
+	names := make(map[string]bool, len(a.Sources))
+	for i := range a.Sources {
+		source := &a.Sources[i]
+		if err := source.Validate(a); err != nil {
+			return fmt.Errorf("sources[%d]: %w", i, err)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("sources[%d]: duplicate name %s", i, source.Name)
+		}
+		names[source.Name] = true
+	}
+	return nil
+}
+
+// Validate fills the fields left empty from the defaults in parent.
+func (s *AutoImportSource) Validate(parent *AutoImportConfig) error {
 	validate := validator.New(validator.WithRequiredStructEnabled())
-	if err := validate.Struct(a); err != nil {
+	if err := validate.Struct(s); err != nil {
 		return fmt.Errorf("validate struct: %w", err)
 	}
 
-	d, err := time.ParseDuration(a.EveryStr)
+	if s.EveryStr != "" {
+		d, err := parseAutoImportEvery(s.EveryStr)
+		if err != nil {
+			return err
+		}
+		s.Every = d
+	} else if parent.Every > 0 {
+		s.Every = parent.Every
+	} else {
+		return fmt.Errorf("every is required")
+	}
+
+	if s.Name == "" {
+		s.Name = s.Path
+	}
+	if s.Glob == "" {
+		s.Glob = defaultAutoImportGlob
+	}
+	if _, err := filepath.Match(s.Glob, ""); err != nil {
+		return fmt.Errorf("glob %s: %w", s.Glob, err)
+	}
+	if s.Source == "" {
+		s.Source = parent.Source
+	}
+	if s.Policy == "" {
+		s.Policy = AutoImportNewest
+	}
+	return nil
+}
+
+func parseAutoImportEvery(every string) (time.Duration, error) {
+	d, err := time.ParseDuration(every)
 	if err != nil {
-		return fmt.Errorf("parse duration: %w", err)
+		return 0, fmt.Errorf("parse duration: %w", err)
 	}
 	if d <= 0 {
-		return fmt.Errorf("duration must be positive")
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
+
+// ImportersConfig holds options of importers that can't be expressed by the source file itself.
+type ImportersConfig struct {
+	SuperProductivity SuperProductivityImportConfig `yaml:"superproductivity"`
+	ActivityWatch     ActivityWatchImportConfig     `yaml:"activitywatch"`
+	Git               GitImportConfig               `yaml:"git"`
+}
+
+func (i *ImportersConfig) Validate() error {
+	if err := i.SuperProductivity.Validate(); err != nil {
+		return fmt.Errorf("superproductivity: %w", err)
+	}
+	if err := i.ActivityWatch.Validate(); err != nil {
+		return fmt.Errorf("activitywatch: %w", err)
+	}
+	if err := i.Git.Validate(); err != nil {
+		return fmt.Errorf("git: %w", err)
+	}
+	return nil
+}
+
+// Ways to attribute time tracked on Super Productivity subtasks.
+const (
+	// SubtasksParent keeps all time on the parent task, subtasks get nothing.
+	SubtasksParent = "parent"
+	// SubtasksOnly moves subtask time from the parent total to the subtasks.
+	SubtasksOnly = "subtask"
+	// SubtasksBoth splits time like SubtasksOnly and links every subtask
+	// timer to its parent, so reports can show both levels.
+	SubtasksBoth = "both"
+)
+
+type SuperProductivityImportConfig struct {
+	Subtasks string `yaml:"subtasks" validate:"omitempty,oneof=parent subtask both"`
+}
+
+func (s *SuperProductivityImportConfig) Validate() error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(s); err != nil {
+		return fmt.Errorf("validate struct: %w", err)
+	}
+
+	if s.Subtasks == "" {
+		s.Subtasks = SubtasksParent
+	}
+	return nil
+}
+
+// ActivityWatchImportConfig selects which window events count as focus time.
+// An event matches if its app is listed in Apps (case-insensitive) or its
+// window title matches one of the Titles regular expressions.
+type ActivityWatchImportConfig struct {
+	Apps     []string         `yaml:"apps"`
+	Titles   []string         `yaml:"titles"`
+	TitlesRe []*regexp.Regexp `yaml:"-"`
+}
+
+func (a *ActivityWatchImportConfig) Validate() error {
+	a.TitlesRe = make([]*regexp.Regexp, 0, len(a.Titles))
+	for _, title := range a.Titles {
+		re, err := regexp.Compile(title)
+		if err != nil {
+			return fmt.Errorf("compile title %q: %w", title, err)
+		}
+		a.TitlesRe = append(a.TitlesRe, re)
+	}
+	return nil
+}
+
+const (
+	defaultGitSessionGap    = 2 * time.Hour
+	defaultGitSessionWarmUp = 30 * time.Minute
+)
+
+// GitImportConfig controls how commit timestamps are turned into coding sessions.
+// Commits closer than Gap belong to one session, and WarmUp is credited before
+// the first commit of every session.
+type GitImportConfig struct {
+	GapStr    string        `yaml:"gap"`
+	Gap       time.Duration `yaml:"-"`
+	WarmUpStr string        `yaml:"warmup"`
+	WarmUp    time.Duration `yaml:"-"`
+	Author    string        `yaml:"author"`
+}
+
+func (g *GitImportConfig) Validate() error {
+	g.Gap = defaultGitSessionGap
+	if g.GapStr != "" {
+		d, err := time.ParseDuration(g.GapStr)
+		if err != nil {
+			return fmt.Errorf("parse gap: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("gap must be positive")
+		}
+		g.Gap = d
+	}
+
+	g.WarmUp = defaultGitSessionWarmUp
+	if g.WarmUpStr != "" {
+		d, err := time.ParseDuration(g.WarmUpStr)
+		if err != nil {
+			return fmt.Errorf("parse warmup: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("warmup must not be negative")
+		}
+		g.WarmUp = d
 	}
-	a.Every = d
 	return nil
 }
 
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"slices"
+	"time"
+)
+
+type TaskCompletionsRepository interface {
+	SaveBatch(completions []models.TaskCompletionModel) (CompletionsSaveResult, error)
+	SaveBatchInRun(runId int, completions []models.TaskCompletionModel) (CompletionsSaveResult, error) // Запоминает прежние значения для import undo
+	CountByDate(day time.Time) (int, error)
+	DaysBetween(startDate, endDate time.Time) ([]time.Time, error)
+}
+
+// CompletionsSaveResult counts new completions and lists the days they changed.
+type CompletionsSaveResult struct {
+	New  int
+	Days []time.Time
+}
+
+type taskCompletionsRepository struct {
+	db DB
+}
+
+func NewTaskCompletionsRepository(db DB) TaskCompletionsRepository {
+	return &taskCompletionsRepository{db: db}
+}
+
+func (r *taskCompletionsRepository) SaveBatch(completions []models.TaskCompletionModel) (CompletionsSaveResult, error) {
+	return r.saveBatch(completions, nil)
+}
+
+func (r *taskCompletionsRepository) SaveBatchInRun(runId int, completions []models.TaskCompletionModel) (CompletionsSaveResult, error) {
+	return r.saveBatch(completions, &runId)
+}
+
+func (r *taskCompletionsRepository) saveBatch(completions []models.TaskCompletionModel, runId *int) (CompletionsSaveResult, error) {
+	var result CompletionsSaveResult
+
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return result, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	selectStored, err := tx.Prepare("SELECT title, done_at, day FROM task_completions WHERE external_id = ?")
+	if err != nil {
+		return result, fmt.Errorf("prepare select: %w", err)
+	}
+	defer selectStored.Close()
+
+	var recordChange *sql.Stmt
+	if runId != nil {
+		recordChange, err = tx.Prepare(`
+			INSERT INTO import_run_completions (run_id, external_id, kind, day, prev_title, prev_done_at, prev_day)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return result, fmt.Errorf("prepare record change: %w", err)
+		}
+		defer recordChange.Close()
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO task_completions (external_id, title, done_at, day)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(external_id) DO UPDATE SET
+			title = excluded.title,
+			done_at = excluded.done_at,
+			day = excluded.day`)
+	if err != nil {
+		return result, fmt.Errorf("prepare upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	touch := func(day time.Time) {
+		if !slices.ContainsFunc(result.Days, day.Equal) {
+			result.Days = append(result.Days, day)
+		}
+	}
+
+	for _, c := range completions {
+		day := c.Day.Format(constnats.DateLayout)
+
+		var stored struct {
+			title  string
+			doneAt time.Time
+			day    string
+		}
+		err := selectStored.QueryRow(c.ExternalId).Scan(&stored.title, &stored.doneAt, &stored.day)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			result.New++
+			touch(c.Day)
+			if recordChange != nil {
+				if _, err := recordChange.Exec(*runId, c.ExternalId, runChangeInsert, day, nil, nil, nil); err != nil {
+					return result, fmt.Errorf("record insert of task completion %s: %w", c.ExternalId, err)
+				}
+			}
+		case err != nil:
+			return result, fmt.Errorf("select task completion %s: %w", c.ExternalId, err)
+		default:
+			storedDay := parseStoredDate(stored.day)
+			if !storedDay.Equal(c.Day) {
+				// the task was reopened and done again on another day
+				touch(storedDay)
+				touch(c.Day)
+			}
+			changed := stored.title != c.Title || !stored.doneAt.Equal(c.DoneAt) || !storedDay.Equal(c.Day)
+			if recordChange != nil && changed {
+				prevDay := storedDay.Format(constnats.DateLayout)
+				if _, err := recordChange.Exec(*runId, c.ExternalId, runChangeUpdate, day, stored.title, stored.doneAt, prevDay); err != nil {
+					return result, fmt.Errorf("record update of task completion %s: %w", c.ExternalId, err)
+				}
+			}
+		}
+
+		if _, err := stmt.Exec(c.ExternalId, c.Title, c.DoneAt, day); err != nil {
+			return result, fmt.Errorf("upsert task completion %s: %w", c.ExternalId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("commit tx: %w", err)
+	}
+	return result, nil
+}
+
+func (r *taskCompletionsRepository) CountByDate(day time.Time) (int, error) {
+	var cnt int
+	err := r.db.QueryRow(`SELECT count(*) FROM task_completions WHERE day = ?`, day.Format(constnats.DateLayout)).Scan(&cnt)
+	if err != nil {
+		return 0, fmt.Errorf("count task completions: %w", err)
+	}
+	return cnt, nil
+}
+
+func (r *taskCompletionsRepository) DaysBetween(startDate, endDate time.Time) ([]time.Time, error) {
+	return queryDays(r.db, `
+		SELECT DISTINCT day FROM task_completions
+		WHERE day BETWEEN ? AND ?
+		ORDER BY day`,
+		startDate.Format(constnats.DateLayout),
+		endDate.Format(constnats.DateLayout),
+	)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomificator/internal/models"
+	"time"
+)
+
+type ImportedFilesRepository interface {
+	Get(path string) (*models.ImportedFileModel, error) // Возвращает nil, если файл еще не импортировался
+	Save(file models.ImportedFileModel) error
+}
+
+type importedFilesRepository struct {
+	db DB
+}
+
+func NewImportedFilesRepository(db DB) ImportedFilesRepository {
+	return &importedFilesRepository{db: db}
+}
+
+func (r *importedFilesRepository) Get(path string) (*models.ImportedFileModel, error) {
+	var f models.ImportedFileModel
+	var mtime int64
+	var importedAt time.Time
+
+	err := r.db.QueryRow(`
+		SELECT path, size, mtime_unix_nano, content_hash, imported_at
+		FROM imported_files
+		WHERE path = ?`, path).Scan(&f.Path, &f.Size, &mtime, &f.ContentHash, &importedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query imported file: %w", err)
+	}
+
+	f.ModTime = time.Unix(0, mtime)
+	f.ImportedAt = &importedAt
+	return &f, nil
+}
+
+func (r *importedFilesRepository) Save(file models.ImportedFileModel) error {
+	_, err := r.db.Exec(`
+		INSERT INTO imported_files (path, size, mtime_unix_nano, content_hash, imported_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(path) DO UPDATE SET
+			size = excluded.size,
+			mtime_unix_nano = excluded.mtime_unix_nano,
+			content_hash = excluded.content_hash,
+			imported_at = excluded.imported_at`,
+		file.Path,
+		file.Size,
+		file.ModTime.UnixNano(),
+		file.ContentHash,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert imported file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,465 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomificator/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of import_run_changes rows.
+const (
+	runChangeInsert = "insert"
+	runChangeUpdate = "update"
+	runChangeDelete = "delete"
+)
+
+type ImportRunsRepository interface {
+	Start(source, file string) (int, error)
+	Finish(id int, saved SaveBatchResult, deleted int, runErr error) error
+	List(limit int) ([]models.ImportRunModel, error) // Новые запуски первыми
+	Days(id int) ([]time.Time, error)                // Дни таймеров и выполненных задач, измененных запуском
+	// Undo restores the timers and task completions changed by a done or
+	// failed run and returns the days whose rewards have to be recalculated,
+	// the days are queued as well. Failed runs of older versions may have
+	// saved a part of their changes.
+	Undo(id int) ([]time.Time, error)
+}
+
+type importRunsRepository struct {
+	db DB
+}
+
+func NewImportRunsRepository(db DB) ImportRunsRepository {
+	return &importRunsRepository{db: db}
+}
+
+func (r *importRunsRepository) Start(source, file string) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		INSERT INTO import_runs (source, file, started_at, status)
+		VALUES (?, ?, CURRENT_TIMESTAMP, ?)
+		RETURNING id`, source, file, models.ImportRunRunning).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert import run: %w", err)
+	}
+	return id, nil
+}
+
+func (r *importRunsRepository) Finish(id int, saved SaveBatchResult, deleted int, runErr error) error {
+	status, errText := models.ImportRunDone, ""
+	if runErr != nil {
+		status, errText = models.ImportRunFailed, runErr.Error()
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE import_runs
+		SET finished_at = CURRENT_TIMESTAMP, inserted = ?, updated = ?, unchanged = ?, deleted = ?, status = ?, error = ?
+		WHERE id = ?`,
+		saved.Inserted, saved.Updated, saved.Unchanged, deleted, status, errText, id)
+	if err != nil {
+		return fmt.Errorf("update import run %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *importRunsRepository) List(limit int) ([]models.ImportRunModel, error) {
+	rows, err := r.db.Query(`
+		SELECT id, source, file, started_at, finished_at, inserted, updated, unchanged, deleted, status, error
+		FROM import_runs
+		ORDER BY id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query import runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.ImportRunModel
+	for rows.Next() {
+		var run models.ImportRunModel
+		var finishedAt sql.NullTime
+		if err := rows.Scan(
+			&run.Id,
+			&run.Source,
+			&run.File,
+			&run.StartedAt,
+			&finishedAt,
+			&run.Inserted,
+			&run.Updated,
+			&run.Unchanged,
+			&run.Deleted,
+			&run.Status,
+			&run.Error,
+		); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return runs, nil
+}
+
+func (r *importRunsRepository) Days(id int) ([]time.Time, error) {
+	rows, err := r.db.Query(`
+		SELECT day FROM import_run_changes WHERE run_id = ?
+		UNION
+		SELECT prev_fixed_at FROM import_run_changes WHERE run_id = ? AND prev_fixed_at IS NOT NULL
+		UNION
+		SELECT day FROM import_run_completions WHERE run_id = ?
+		UNION
+		SELECT prev_day FROM import_run_completions WHERE run_id = ? AND prev_day IS NOT NULL
+		ORDER BY 1`, id, id, id, id)
+	if err != nil {
+		return nil, fmt.Errorf("query run days: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		days = append(days, parseStoredDate(day))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return days, nil
+}
+
+type runChange struct {
+	id          int
+	timerId     int
+	kind        string
+	day         sql.NullString
+	prevFixedAt sql.NullString
+	prevTagIds  sql.NullString
+}
+
+func (r *importRunsRepository) Undo(id int) ([]time.Time, error) {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM import_runs WHERE id = ?", id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("import run %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select import run: %w", err)
+	}
+	if status != models.ImportRunDone && status != models.ImportRunFailed {
+		return nil, fmt.Errorf("import run %d is %s, only %s and %s runs can be undone", id, status, models.ImportRunDone, models.ImportRunFailed)
+	}
+
+	// restoring values overwritten again later would silently drop the later run
+	var laterRun sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT min(later.run_id)
+		FROM import_run_changes mine
+		JOIN import_run_changes later ON later.timer_id = mine.timer_id AND later.run_id > mine.run_id
+		JOIN import_runs lr ON lr.id = later.run_id
+		WHERE mine.run_id = ? AND lr.status IN (?, ?)`, id, models.ImportRunDone, models.ImportRunFailed).Scan(&laterRun)
+	if err != nil {
+		return nil, fmt.Errorf("select later runs: %w", err)
+	}
+	if laterRun.Valid {
+		return nil, fmt.Errorf("timers of import run %d were changed by import run %d, undo it first", id, laterRun.Int64)
+	}
+	err = tx.QueryRow(`
+		SELECT min(later.run_id)
+		FROM import_run_completions mine
+		JOIN import_run_completions later ON later.external_id = mine.external_id AND later.run_id > mine.run_id
+		JOIN import_runs lr ON lr.id = later.run_id
+		WHERE mine.run_id = ? AND lr.status IN (?, ?)`, id, models.ImportRunDone, models.ImportRunFailed).Scan(&laterRun)
+	if err != nil {
+		return nil, fmt.Errorf("select later runs of completions: %w", err)
+	}
+	if laterRun.Valid {
+		return nil, fmt.Errorf("task completions of import run %d were changed by import run %d, undo it first", id, laterRun.Int64)
+	}
+
+	changes, err := loadRunChanges(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load changes: %w", err)
+	}
+
+	var days []time.Time
+	addDay := func(value sql.NullString) {
+		if !value.Valid {
+			return
+		}
+		day := parseStoredDate(value.String)
+		if !slices.ContainsFunc(days, day.Equal) {
+			days = append(days, day)
+		}
+	}
+
+	for _, change := range changes {
+		addDay(change.day)
+		addDay(change.prevFixedAt)
+
+		switch change.kind {
+		case runChangeInsert:
+			if _, err := tx.Exec("DELETE FROM timer_tags WHERE timer_id = ?", change.timerId); err != nil {
+				return nil, fmt.Errorf("delete tags of timer %d: %w", change.timerId, err)
+			}
+			if _, err := tx.Exec("DELETE FROM timers WHERE id = ?", change.timerId); err != nil {
+				return nil, fmt.Errorf("delete timer %d: %w", change.timerId, err)
+			}
+			continue
+		case runChangeUpdate, runChangeDelete:
+			res, err := tx.Exec(`
+				UPDATE timers
+				SET (fixed_at, seconds_spent, ended_at, name, description, parent_external_id, project_id, deleted_at) = (
+					SELECT prev_fixed_at, prev_seconds_spent, prev_ended_at, prev_name, prev_description, prev_parent_external_id, prev_project_id, prev_deleted_at
+					FROM import_run_changes WHERE id = ?
+				)
+				WHERE id = ?`, change.id, change.timerId)
+			if err != nil {
+				return nil, fmt.Errorf("restore timer %d: %w", change.timerId, err)
+			}
+			restored, err := res.RowsAffected()
+			if err != nil {
+				return nil, fmt.Errorf("rows affected: %w", err)
+			}
+			if restored == 0 {
+				// the run removed the row, bring it back under its old id
+				_, err := tx.Exec(`
+					INSERT INTO timers (id, external_id, fixed_at, seconds_spent, ended_at, name, description, parent_external_id, project_id, deleted_at, created_at)
+					SELECT timer_id, external_id, prev_fixed_at, prev_seconds_spent, prev_ended_at, prev_name, prev_description, prev_parent_external_id, prev_project_id, prev_deleted_at, prev_created_at
+					FROM import_run_changes WHERE id = ?`, change.id)
+				if err != nil {
+					return nil, fmt.Errorf("reinsert timer %d: %w", change.timerId, err)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown change kind %s", change.kind)
+		}
+
+		if err := restoreTimerTags(tx, change.timerId, change.prevTagIds); err != nil {
+			return nil, fmt.Errorf("restore tags of timer %d: %w", change.timerId, err)
+		}
+	}
+
+	completionDays, err := undoRunCompletions(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("undo task completions: %w", err)
+	}
+	for _, day := range completionDays {
+		if !slices.ContainsFunc(days, day.Equal) {
+			days = append(days, day)
+		}
+	}
+
+	slices.SortFunc(days, time.Time.Compare)
+
+	if _, err := tx.Exec("UPDATE import_runs SET status = ? WHERE id = ?", models.ImportRunUndone, id); err != nil {
+		return nil, fmt.Errorf("update import run: %w", err)
+	}
+	if err := queuePendingRewardDays(tx, days); err != nil {
+		return nil, fmt.Errorf("queue pending reward days: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return days, nil
+}
+
+// loadRunChanges returns the changes of a run, newest first.
+func loadRunChanges(tx DB, runId int) ([]runChange, error) {
+	rows, err := tx.Query(`
+		SELECT id, timer_id, kind, day, prev_fixed_at, prev_tag_ids
+		FROM import_run_changes
+		WHERE run_id = ?
+		ORDER BY id DESC`, runId)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []runChange
+	for rows.Next() {
+		var c runChange
+		if err := rows.Scan(&c.id, &c.timerId, &c.kind, &c.day, &c.prevFixedAt, &c.prevTagIds); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return changes, nil
+}
+
+// undoRunCompletions deletes the task completions a run inserted and restores
+// the ones it updated, newest change first. It returns the days they were on.
+func undoRunCompletions(tx DB, runId int) ([]time.Time, error) {
+	rows, err := tx.Query(`
+		SELECT id, external_id, kind, day, prev_day
+		FROM import_run_completions
+		WHERE run_id = ?
+		ORDER BY id DESC`, runId)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	type completionChange struct {
+		id         int
+		externalId string
+		kind       string
+		day        string
+		prevDay    sql.NullString
+	}
+	var changes []completionChange
+	for rows.Next() {
+		var c completionChange
+		if err := rows.Scan(&c.id, &c.externalId, &c.kind, &c.day, &c.prevDay); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	var days []time.Time
+	for _, change := range changes {
+		days = append(days, parseStoredDate(change.day))
+		if change.prevDay.Valid {
+			days = append(days, parseStoredDate(change.prevDay.String))
+		}
+
+		switch change.kind {
+		case runChangeInsert:
+			if _, err := tx.Exec("DELETE FROM task_completions WHERE external_id = ?", change.externalId); err != nil {
+				return nil, fmt.Errorf("delete task completion %s: %w", change.externalId, err)
+			}
+		case runChangeUpdate:
+			_, err := tx.Exec(`
+				UPDATE task_completions
+				SET (title, done_at, day) = (
+					SELECT prev_title, prev_done_at, prev_day FROM import_run_completions WHERE id = ?
+				)
+				WHERE external_id = ?`, change.id, change.externalId)
+			if err != nil {
+				return nil, fmt.Errorf("restore task completion %s: %w", change.externalId, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown change kind %s", change.kind)
+		}
+	}
+	return days, nil
+}
+
+func restoreTimerTags(tx DB, timerId int, tagIds sql.NullString) error {
+	if _, err := tx.Exec("DELETE FROM timer_tags WHERE timer_id = ?", timerId); err != nil {
+		return fmt.Errorf("delete timer tags: %w", err)
+	}
+	if !tagIds.Valid || tagIds.String == "" {
+		return nil
+	}
+	for _, raw := range strings.Split(tagIds.String, ",") {
+		tagId, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("parse tag id %q: %w", raw, err)
+		}
+		if _, err := tx.Exec("INSERT INTO timer_tags (timer_id, tag_id) VALUES (?, ?)", timerId, tagId); err != nil {
+			return fmt.Errorf("insert timer tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// runChangesWriter records the previous values of timers changed by a run,
+// it works inside the transaction of the change itself.
+type runChangesWriter struct {
+	runId    int
+	snapshot *sql.Stmt
+	insert   *sql.Stmt
+	drop     *sql.Stmt
+}
+
+func newRunChangesWriter(tx DB, runId int) (*runChangesWriter, error) {
+	w := &runChangesWriter{runId: runId}
+	var err error
+
+	w.snapshot, err = tx.Prepare(`
+		INSERT INTO import_run_changes (
+			run_id, timer_id, kind, day, external_id,
+			prev_fixed_at, prev_seconds_spent, prev_ended_at, prev_name, prev_description, prev_parent_external_id,
+			prev_project_id, prev_deleted_at, prev_created_at, prev_tag_ids
+		)
+		SELECT ?, id, ?, COALESCE(?, fixed_at), external_id,
+			fixed_at, seconds_spent, ended_at, name, description, parent_external_id,
+			project_id, deleted_at, created_at,
+			(SELECT group_concat(tag_id) FROM timer_tags WHERE timer_id = timers.id)
+		FROM timers
+		WHERE id = ?
+		RETURNING id`)
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("prepare snapshot: %w", err)
+	}
+	w.insert, err = tx.Prepare(`
+		INSERT INTO import_run_changes (run_id, timer_id, kind, day, external_id)
+		VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("prepare insert: %w", err)
+	}
+	w.drop, err = tx.Prepare("DELETE FROM import_run_changes WHERE id = ?")
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("prepare drop: %w", err)
+	}
+	return w, nil
+}
+
+func (w *runChangesWriter) Close() {
+	for _, stmt := range []*sql.Stmt{w.snapshot, w.insert, w.drop} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// before saves the current values of a timer that is about to change. day is
+// the date the timer will have afterwards, nil keeps the current one.
+func (w *runChangesWriter) before(timerId int, kind string, day *string) (int64, error) {
+	var id int64
+	if err := w.snapshot.QueryRow(w.runId, kind, day, timerId).Scan(&id); err != nil {
+		return 0, fmt.Errorf("snapshot timer %d: %w", timerId, err)
+	}
+	return id, nil
+}
+
+// forget removes a snapshot of a timer that didn't change after all.
+func (w *runChangesWriter) forget(changeId int64) error {
+	if _, err := w.drop.Exec(changeId); err != nil {
+		return fmt.Errorf("drop change %d: %w", changeId, err)
+	}
+	return nil
+}
+
+func (w *runChangesWriter) inserted(timerId int64, day string, externalId sql.NullString) error {
+	if _, err := w.insert.Exec(w.runId, timerId, runChangeInsert, day, externalId); err != nil {
+		return fmt.Errorf("record insert of timer %d: %w", timerId, err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"errors"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCompletion(t *testing.T, externalId, title, day string) models.TaskCompletionModel {
+	t.Helper()
+	d := testDay(t, day)
+	return models.TaskCompletionModel{ExternalId: externalId, Title: title, DoneAt: d.Add(10 * time.Hour), Day: d}
+}
+
+// finishedRun records a run with the timers and completions it saved and the
+// timers it deleted.
+func finishedRun(t *testing.T, strg *Storage, timers []models.TimerModel, completions []models.TaskCompletionModel, deleted []string, soft bool) int {
+	t.Helper()
+
+	runId, err := strg.ImportRunsRepo.Start("test", "")
+	if err != nil {
+		t.Fatalf("start run: %v", err)
+	}
+	saved, err := strg.TimersRepo.SaveBatchInRun(runId, timers)
+	if err != nil {
+		t.Fatalf("save timers: %v", err)
+	}
+	if _, err := strg.CompletionsRepo.SaveBatchInRun(runId, completions); err != nil {
+		t.Fatalf("save completions: %v", err)
+	}
+	if len(deleted) > 0 {
+		stored, err := strg.TimersRepo.GetByExternalIds(deleted)
+		if err != nil {
+			t.Fatalf("load timers: %v", err)
+		}
+		var ids []int
+		for _, timer := range stored {
+			ids = append(ids, *timer.Id)
+		}
+		if err := strg.TimersRepo.DeleteManyInRun(runId, ids, soft); err != nil {
+			t.Fatalf("delete timers: %v", err)
+		}
+	}
+	if err := strg.ImportRunsRepo.Finish(runId, saved, len(deleted), nil); err != nil {
+		t.Fatalf("finish run: %v", err)
+	}
+	return runId
+}
+
+// failedRun records a run whose saving fails after the timers. Older versions
+// saved the run in several transactions and kept the timers, partly is true
+// for them.
+func failedRun(t *testing.T, strg *Storage, timers []models.TimerModel, partly bool) int {
+	t.Helper()
+
+	runId, err := strg.ImportRunsRepo.Start("test", "")
+	if err != nil {
+		t.Fatalf("start run: %v", err)
+	}
+	errSave := errors.New("save completions failed")
+	save := func(tx *Storage) error {
+		if _, err := tx.TimersRepo.SaveBatchInRun(runId, timers); err != nil {
+			t.Fatalf("save timers: %v", err)
+		}
+		return errSave
+	}
+	if partly {
+		err = save(strg)
+	} else {
+		err = strg.InTx(save)
+	}
+	if !errors.Is(err, errSave) {
+		t.Fatalf("save run: %v", err)
+	}
+	if err := strg.ImportRunsRepo.Finish(runId, SaveBatchResult{}, 0, err); err != nil {
+		t.Fatalf("finish run: %v", err)
+	}
+	return runId
+}
+
+// storedCompletions returns the days of the stored completions by external id.
+func storedCompletions(t *testing.T, strg *Storage) map[string]string {
+	t.Helper()
+	rows, err := strg.db.Query("SELECT external_id, day FROM task_completions")
+	if err != nil {
+		t.Fatalf("query completions: %v", err)
+	}
+	defer rows.Close()
+
+	completions := make(map[string]string)
+	for rows.Next() {
+		var externalId, day string
+		if err := rows.Scan(&externalId, &day); err != nil {
+			t.Fatalf("row scan: %v", err)
+		}
+		completions[externalId] = parseStoredDate(day).Format(constnats.DateLayout)
+	}
+	return completions
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name string
+		// run prepares the database and returns the run to undo
+		run             func(t *testing.T, strg *Storage) int
+		wantErr         string
+		wantDays        []string
+		wantMinutes     map[string]int
+		wantCompletions map[string]string
+	}{
+		{
+			name: "inserted timers and completions are removed",
+			run: func(t *testing.T, strg *Storage) int {
+				finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				return finishedRun(t, strg,
+					[]models.TimerModel{testTimer(t, "b", "2025-11-11", 20)},
+					[]models.TaskCompletionModel{testCompletion(t, "done:1", "Task", "2025-11-12")},
+					nil, false)
+			},
+			wantDays:        []string{"2025-11-11", "2025-11-12"},
+			wantMinutes:     map[string]int{"a": 30},
+			wantCompletions: map[string]string{},
+		},
+		{
+			name: "updated timers and completions are restored",
+			run: func(t *testing.T, strg *Storage) int {
+				finishedRun(t, strg,
+					[]models.TimerModel{testTimer(t, "a", "2025-11-10", 30)},
+					[]models.TaskCompletionModel{testCompletion(t, "done:1", "Task", "2025-11-10")},
+					nil, false)
+				return finishedRun(t, strg,
+					[]models.TimerModel{testTimer(t, "a", "2025-11-11", 45)},
+					[]models.TaskCompletionModel{testCompletion(t, "done:1", "Task again", "2025-11-12")},
+					nil, false)
+			},
+			wantDays:        []string{"2025-11-10", "2025-11-11", "2025-11-12"},
+			wantMinutes:     map[string]int{"a": 30},
+			wantCompletions: map[string]string{"done:1": "2025-11-10"},
+		},
+		{
+			name: "unchanged completions stay",
+			run: func(t *testing.T, strg *Storage) int {
+				completion := testCompletion(t, "done:1", "Task", "2025-11-10")
+				finishedRun(t, strg, nil, []models.TaskCompletionModel{completion}, nil, false)
+				return finishedRun(t, strg, nil, []models.TaskCompletionModel{completion}, nil, false)
+			},
+			wantMinutes:     map[string]int{},
+			wantCompletions: map[string]string{"done:1": "2025-11-10"},
+		},
+		{
+			name: "soft deleted timers come back",
+			run: func(t *testing.T, strg *Storage) int {
+				finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30), testTimer(t, "b", "2025-11-10", 10)}, nil, nil, false)
+				return finishedRun(t, strg, nil, nil, []string{"a"}, true)
+			},
+			wantDays:        []string{"2025-11-10"},
+			wantMinutes:     map[string]int{"a": 30, "b": 10},
+			wantCompletions: map[string]string{},
+		},
+		{
+			name: "hard deleted timers come back",
+			run: func(t *testing.T, strg *Storage) int {
+				finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				return finishedRun(t, strg, nil, nil, []string{"a"}, false)
+			},
+			wantDays:        []string{"2025-11-10"},
+			wantMinutes:     map[string]int{"a": 30},
+			wantCompletions: map[string]string{},
+		},
+		{
+			name: "timers changed by a later run",
+			run: func(t *testing.T, strg *Storage) int {
+				runId := finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 40)}, nil, nil, false)
+				return runId
+			},
+			wantErr: "undo it first",
+		},
+		{
+			name: "completions changed by a later run",
+			run: func(t *testing.T, strg *Storage) int {
+				runId := finishedRun(t, strg, nil, []models.TaskCompletionModel{testCompletion(t, "done:1", "Task", "2025-11-10")}, nil, false)
+				finishedRun(t, strg, nil, []models.TaskCompletionModel{testCompletion(t, "done:1", "Task", "2025-11-11")}, nil, false)
+				return runId
+			},
+			wantErr: "undo it first",
+		},
+		{
+			name: "run undone twice",
+			run: func(t *testing.T, strg *Storage) int {
+				runId := finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				if _, err := strg.ImportRunsRepo.Undo(runId); err != nil {
+					t.Fatalf("first undo: %v", err)
+				}
+				return runId
+			},
+			wantErr: "only done and failed runs can be undone",
+		},
+		{
+			name: "failed run saved nothing",
+			run: func(t *testing.T, strg *Storage) int {
+				finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				return failedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 40), testTimer(t, "b", "2025-11-11", 20)}, false)
+			},
+			wantMinutes:     map[string]int{"a": 30},
+			wantCompletions: map[string]string{},
+		},
+		{
+			name: "failed run doesn't block an earlier one",
+			run: func(t *testing.T, strg *Storage) int {
+				runId := finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				failedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 40)}, false)
+				return runId
+			},
+			wantDays:        []string{"2025-11-10"},
+			wantMinutes:     map[string]int{},
+			wantCompletions: map[string]string{},
+		},
+		{
+			name: "partly saved failed run is reverted",
+			run: func(t *testing.T, strg *Storage) int {
+				finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				return failedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 40), testTimer(t, "b", "2025-11-11", 20)}, true)
+			},
+			wantDays:        []string{"2025-11-10", "2025-11-11"},
+			wantMinutes:     map[string]int{"a": 30},
+			wantCompletions: map[string]string{},
+		},
+		{
+			name: "partly saved failed run blocks an earlier one",
+			run: func(t *testing.T, strg *Storage) int {
+				runId := finishedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}, nil, nil, false)
+				failedRun(t, strg, []models.TimerModel{testTimer(t, "a", "2025-11-10", 40)}, true)
+				return runId
+			},
+			wantErr: "undo it first",
+		},
+		{
+			name:    "unknown run",
+			run:     func(t *testing.T, strg *Storage) int { return 42 },
+			wantErr: "not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := newTestStorage(t)
+			runId := tt.run(t, strg)
+
+			days, err := strg.ImportRunsRepo.Undo(runId)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Undo() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Undo() error = %v", err)
+			}
+
+			var gotDays []string
+			for _, day := range days {
+				gotDays = append(gotDays, day.Format(constnats.DateLayout))
+			}
+			if !slices.Equal(gotDays, tt.wantDays) {
+				t.Errorf("Undo() days = %v, want %v", gotDays, tt.wantDays)
+			}
+			pending, err := strg.PendingDaysRepo.List()
+			if err != nil {
+				t.Fatalf("pending days: %v", err)
+			}
+			if len(pending) != len(tt.wantDays) {
+				t.Errorf("%d days queued, want %d", len(pending), len(tt.wantDays))
+			}
+			if minutes := storedMinutes(t, strg); !maps.Equal(minutes, tt.wantMinutes) {
+				t.Errorf("stored minutes = %v, want %v", minutes, tt.wantMinutes)
+			}
+			if completions := storedCompletions(t, strg); !maps.Equal(completions, tt.wantCompletions) {
+				t.Errorf("stored completions = %v, want %v", completions, tt.wantCompletions)
+			}
+		})
+	}
+}
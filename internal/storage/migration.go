@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"database/sql"
 	"embed"
 	"fmt"
 
@@ -11,8 +12,12 @@ import (
 var migrationFS embed.FS
 
 func MigrateDb(strg *Storage) error {
+	db, ok := strg.db.(*sql.DB)
+	if !ok {
+		return fmt.Errorf("migrate db: migrations can't run in a transaction")
+	}
 	goose.SetBaseFS(migrationFS)
-	if err := goose.Up(strg.db, "migrations"); err != nil {
+	if err := goose.Up(db, "migrations"); err != nil {
 		return fmt.Errorf("migrate db: %w", err)
 	}
 
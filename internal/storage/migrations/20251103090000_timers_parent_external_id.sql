@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE timers ADD COLUMN parent_external_id TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE timers DROP COLUMN parent_external_id;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS task_completions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    external_id TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL DEFAULT '',
+    done_at TIMESTAMP NOT NULL,
+    day DATE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS task_completions_day ON task_completions(day);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_completions;
+-- +goose StatementEnd
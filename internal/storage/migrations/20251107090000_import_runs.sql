@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS import_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,
+    file TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP NULL,
+    inserted INT NOT NULL DEFAULT 0,
+    updated INT NOT NULL DEFAULT 0,
+    unchanged INT NOT NULL DEFAULT 0,
+    deleted INT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+-- import_run_changes keeps the values a timer had before a run touched it,
+-- prev_* columns are NULL for inserted timers.
+CREATE TABLE IF NOT EXISTS import_run_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL REFERENCES import_runs(id),
+    timer_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    day DATE NOT NULL,
+    external_id TEXT NULL,
+    prev_fixed_at DATE NULL,
+    prev_seconds_spent INT NULL,
+    prev_name TEXT NULL,
+    prev_description TEXT NULL,
+    prev_parent_external_id TEXT NULL,
+    prev_project_id INTEGER NULL,
+    prev_deleted_at TIMESTAMP NULL,
+    prev_created_at TIMESTAMP NULL,
+    prev_tag_ids TEXT NULL
+);
+CREATE INDEX IF NOT EXISTS import_run_changes_run_id ON import_run_changes(run_id);
+CREATE INDEX IF NOT EXISTS import_run_changes_timer_id ON import_run_changes(timer_id);
+
+CREATE TABLE IF NOT EXISTS pending_reward_days (
+    day DATE PRIMARY KEY,
+    queued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pending_reward_days;
+DROP TABLE IF EXISTS import_run_changes;
+DROP TABLE IF EXISTS import_runs;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS settlement (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_settled DATE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS settlement;
+-- +goose StatementEnd
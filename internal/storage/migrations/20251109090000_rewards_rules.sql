@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS rewards_rules (
+    day DATE PRIMARY KEY,
+    day_type TEXT NOT NULL,
+    rules TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rewards_rules;
+-- +goose StatementEnd
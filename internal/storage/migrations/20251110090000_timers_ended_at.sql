@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE timers ADD COLUMN ended_at TIMESTAMP NULL;
+ALTER TABLE import_run_changes ADD COLUMN prev_ended_at TIMESTAMP NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE import_run_changes DROP COLUMN prev_ended_at;
+ALTER TABLE timers DROP COLUMN ended_at;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS rewards_periods (
+    period TEXT NOT NULL,
+    start DATE NOT NULL,
+    medal_type TEXT NOT NULL,
+    count INT NOT NULL,
+    PRIMARY KEY(period, start, medal_type)
+);
+
+CREATE TABLE IF NOT EXISTS rewards_period_rules (
+    period TEXT NOT NULL,
+    start DATE NOT NULL,
+    rules TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    PRIMARY KEY(period, start)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rewards_period_rules;
+DROP TABLE IF EXISTS rewards_periods;
+-- +goose StatementEnd
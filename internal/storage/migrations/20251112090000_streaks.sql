@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS streak_days (
+    day DATE PRIMARY KEY,
+    status TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS streak_rewards (
+    streak_start DATE NOT NULL,
+    days INT NOT NULL,
+    medal_type TEXT NOT NULL,
+    count INT NOT NULL,
+    PRIMARY KEY(streak_start, days)
+);
+
+CREATE TABLE IF NOT EXISTS inventory (
+    item TEXT PRIMARY KEY,
+    count INT NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS inventory;
+DROP TABLE IF EXISTS streak_rewards;
+DROP TABLE IF EXISTS streak_days;
+-- +goose StatementEnd
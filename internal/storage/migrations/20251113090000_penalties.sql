@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS penalties_daily (
+    day DATE NOT NULL,
+    medal_type TEXT NOT NULL,
+    count INT NOT NULL,
+    PRIMARY KEY(day, medal_type)
+);
+
+CREATE TABLE IF NOT EXISTS shop_blocks (
+    day DATE PRIMARY KEY,
+    until DATE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shop_blocks;
+DROP TABLE IF EXISTS penalties_daily;
+-- +goose StatementEnd
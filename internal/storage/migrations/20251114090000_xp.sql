@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS xp_events (
+    day DATE NOT NULL,
+    source TEXT NOT NULL,
+    xp INT NOT NULL,
+    PRIMARY KEY(day, source)
+);
+
+CREATE TABLE IF NOT EXISTS level_ups (
+    level INT PRIMARY KEY,
+    name TEXT NOT NULL,
+    day DATE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS level_ups;
+DROP TABLE IF EXISTS xp_events;
+-- +goose StatementEnd
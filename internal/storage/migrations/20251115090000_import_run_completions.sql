@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- import_run_completions keeps the values a task completion had before a run
+-- touched it, prev_* columns are NULL for inserted completions.
+CREATE TABLE IF NOT EXISTS import_run_completions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL REFERENCES import_runs(id),
+    external_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    day DATE NOT NULL,
+    prev_title TEXT NULL,
+    prev_done_at TIMESTAMP NULL,
+    prev_day DATE NULL
+);
+CREATE INDEX IF NOT EXISTS import_run_completions_run_id ON import_run_completions(run_id);
+CREATE INDEX IF NOT EXISTS import_run_completions_external_id ON import_run_completions(external_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_run_completions;
+-- +goose StatementEnd
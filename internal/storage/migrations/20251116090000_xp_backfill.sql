@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- xp_backfill_days are the days settled before XP existed, the next
+-- settlement records their XP.
+CREATE TABLE IF NOT EXISTS xp_backfill_days (
+    day DATE PRIMARY KEY
+);
+
+INSERT OR IGNORE INTO xp_backfill_days (day)
+SELECT date(day) FROM (
+    SELECT fixed_at AS day FROM timers, settlement
+    WHERE timers.deleted_at IS NULL AND date(fixed_at) <= date(settlement.last_settled)
+    UNION
+    SELECT day FROM task_completions, settlement
+    WHERE date(day) <= date(settlement.last_settled)
+    UNION
+    SELECT day FROM rewards_daily
+    UNION
+    SELECT day FROM penalties_daily
+    UNION
+    SELECT day FROM streak_days
+)
+WHERE date(day) NOT IN (SELECT date(day) FROM xp_events);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS xp_backfill_days;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The first backfill only took days up to the last settlement, which
+-- databases from before automatic settlement don't have. Every stored day
+-- earns its minute and task XP.
+INSERT OR IGNORE INTO xp_backfill_days (day)
+SELECT date(day) FROM (
+    SELECT fixed_at AS day FROM timers WHERE deleted_at IS NULL
+    UNION
+    SELECT day FROM task_completions
+)
+WHERE date(day) NOT IN (SELECT date(day) FROM xp_events);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the queued days are left to the next settlement
+SELECT 1;
+-- +goose StatementEnd
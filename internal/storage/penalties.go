@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"time"
+)
+
+// PenaltiesRepository keeps the medals taken by the penalties of settled days,
+// as negative counts, and the shop purchases they blocked.
+type PenaltiesRepository interface {
+	LoadByDate(day time.Time) (models.WalletModel, error)
+	ReplaceForDate(day time.Time, penalty models.WalletModel, until *time.Time) error // until - первый день без блокировки покупок, nil - без блокировки
+	BlockedUntil() (*time.Time, error)                                                // Возвращает nil, если покупки никогда не блокировались
+	DaysBetween(startDate, endDate time.Time) ([]time.Time, error)
+}
+
+type penaltiesRepository struct {
+	db DB
+}
+
+func NewPenaltiesRepository(db DB) PenaltiesRepository {
+	return &penaltiesRepository{db: db}
+}
+
+func (r *penaltiesRepository) LoadByDate(day time.Time) (models.WalletModel, error) {
+	rows, err := r.db.Query("SELECT medal_type, count FROM penalties_daily WHERE day = ?", day.Format(constnats.DateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("query penalties_daily: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(models.WalletModel)
+	for rows.Next() {
+		var medal string
+		var cnt int
+		if err := rows.Scan(&medal, &cnt); err != nil {
+			return nil, fmt.Errorf("scan penalties_daily: %w", err)
+		}
+		m, err := constnats.LoadMedal(medal)
+		if err != nil {
+			return nil, fmt.Errorf("load medal: %w", err)
+		}
+		out[m] = cnt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate penalties_daily: %w", err)
+	}
+	return out, nil
+}
+
+func (r *penaltiesRepository) ReplaceForDate(day time.Time, penalty models.WalletModel, until *time.Time) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM penalties_daily WHERE day = ?", day.Format(constnats.DateLayout)); err != nil {
+		return fmt.Errorf("delete old penalties_daily: %w", err)
+	}
+	for medal, cnt := range penalty {
+		if cnt == 0 {
+			continue
+		}
+		_, err := tx.Exec("INSERT INTO penalties_daily(day, medal_type, count) VALUES(?, ?, ?)",
+			day.Format(constnats.DateLayout), string(medal), cnt)
+		if err != nil {
+			return fmt.Errorf("insert penalties_daily %s: %w", medal, err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM shop_blocks WHERE day = ?", day.Format(constnats.DateLayout)); err != nil {
+		return fmt.Errorf("delete old shop_blocks: %w", err)
+	}
+	if until != nil {
+		_, err := tx.Exec("INSERT INTO shop_blocks(day, until) VALUES(?, ?)",
+			day.Format(constnats.DateLayout), until.Format(constnats.DateLayout))
+		if err != nil {
+			return fmt.Errorf("insert shop_blocks: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *penaltiesRepository) BlockedUntil() (*time.Time, error) {
+	var until sql.NullString
+	if err := r.db.QueryRow("SELECT MAX(until) FROM shop_blocks").Scan(&until); err != nil {
+		return nil, fmt.Errorf("query shop_blocks: %w", err)
+	}
+	if !until.Valid {
+		return nil, nil
+	}
+	d := parseStoredDate(until.String)
+	return &d, nil
+}
+
+func (r *penaltiesRepository) DaysBetween(startDate, endDate time.Time) ([]time.Time, error) {
+	return queryDays(r.db, `
+		SELECT day FROM penalties_daily WHERE day BETWEEN ? AND ?
+		UNION
+		SELECT day FROM shop_blocks WHERE day BETWEEN ? AND ?
+		ORDER BY day`,
+		startDate.Format(constnats.DateLayout), endDate.Format(constnats.DateLayout),
+		startDate.Format(constnats.DateLayout), endDate.Format(constnats.DateLayout),
+	)
+}
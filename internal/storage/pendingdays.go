@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"fmt"
+	"gomificator/internal/constnats"
+	"time"
+)
+
+// PendingRewardDaysRepository queues days whose rewards are out of date,
+// e.g. after an import was undone.
+type PendingRewardDaysRepository interface {
+	Add(days []time.Time) error
+	List() ([]time.Time, error)
+	Remove(days []time.Time) error
+}
+
+type pendingRewardDaysRepository struct {
+	db DB
+}
+
+func NewPendingRewardDaysRepository(db DB) PendingRewardDaysRepository {
+	return &pendingRewardDaysRepository{db: db}
+}
+
+func (r *pendingRewardDaysRepository) Add(days []time.Time) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := queuePendingRewardDays(tx, days); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *pendingRewardDaysRepository) List() ([]time.Time, error) {
+	rows, err := r.db.Query("SELECT day FROM pending_reward_days ORDER BY day")
+	if err != nil {
+		return nil, fmt.Errorf("query pending reward days: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		days = append(days, parseStoredDate(day))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return days, nil
+}
+
+func (r *pendingRewardDaysRepository) Remove(days []time.Time) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, day := range days {
+		if _, err := tx.Exec("DELETE FROM pending_reward_days WHERE day = ?", day.Format(constnats.DateLayout)); err != nil {
+			return fmt.Errorf("delete pending reward day: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func queuePendingRewardDays(tx DB, days []time.Time) error {
+	for _, day := range days {
+		_, err := tx.Exec(`
+			INSERT INTO pending_reward_days (day) VALUES (?)
+			ON CONFLICT(day) DO NOTHING`, day.Format(constnats.DateLayout))
+		if err != nil {
+			return fmt.Errorf("insert pending reward day: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"time"
+)
+
+// PeriodRewardsRepository keeps the medals earned by weekly and monthly
+// goals, a period is identified by its kind and first day.
+type PeriodRewardsRepository interface {
+	LoadByPeriod(period string, start time.Time) (models.WalletModel, error)
+	LoadRules(period string, start time.Time) (*models.PeriodRulesModel, error) // Возвращает nil, если правила периода не заморожены
+	ReplaceForPeriod(period string, start time.Time, rewards models.WalletModel, rules models.PeriodRulesModel) error
+}
+
+type periodRewardsRepository struct {
+	db DB
+}
+
+func NewPeriodRewardsRepository(db DB) PeriodRewardsRepository {
+	return &periodRewardsRepository{db: db}
+}
+
+func (r *periodRewardsRepository) LoadByPeriod(period string, start time.Time) (models.WalletModel, error) {
+	rows, err := r.db.Query(`SELECT medal_type, count FROM rewards_periods WHERE period = ? AND start = ?`,
+		period, start.Format(constnats.DateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("query rewards_periods: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(models.WalletModel)
+	for rows.Next() {
+		var medal string
+		var cnt int
+		if err := rows.Scan(&medal, &cnt); err != nil {
+			return nil, fmt.Errorf("scan rewards_periods: %w", err)
+		}
+		m, err := constnats.LoadMedal(medal)
+		if err != nil {
+			return nil, fmt.Errorf("load medal: %w", err)
+		}
+		out[m] = cnt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rewards_periods: %w", err)
+	}
+	return out, nil
+}
+
+func (r *periodRewardsRepository) LoadRules(period string, start time.Time) (*models.PeriodRulesModel, error) {
+	var rules models.PeriodRulesModel
+	err := r.db.QueryRow(`SELECT rules, hash FROM rewards_period_rules WHERE period = ? AND start = ?`,
+		period, start.Format(constnats.DateLayout)).Scan(&rules.Rules, &rules.Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query rewards_period_rules: %w", err)
+	}
+	return &rules, nil
+}
+
+// ReplaceForPeriod replaces the rewards of the period and freezes the goals they were earned with.
+func (r *periodRewardsRepository) ReplaceForPeriod(period string, start time.Time, rewards models.WalletModel, rules models.PeriodRulesModel) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	day := start.Format(constnats.DateLayout)
+	if _, err := tx.Exec(`DELETE FROM rewards_periods WHERE period = ? AND start = ?`, period, day); err != nil {
+		return fmt.Errorf("delete old rewards_periods: %w", err)
+	}
+	for medal, cnt := range rewards {
+		if cnt == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO rewards_periods(period, start, medal_type, count) VALUES(?, ?, ?, ?)`,
+			period, day, string(medal), cnt); err != nil {
+			return fmt.Errorf("insert rewards_periods %s: %w", medal, err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO rewards_period_rules(period, start, rules, hash) VALUES(?, ?, ?, ?)
+		ON CONFLICT(period, start) DO UPDATE SET rules = excluded.rules, hash = excluded.hash`,
+		period, day, rules.Rules, rules.Hash)
+	if err != nil {
+		return fmt.Errorf("upsert rewards_period_rules: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"gomificator/internal/models"
+	"slices"
+	"strings"
+)
+
+// Separators of the tag list selected by timerTagsColumn.
+const (
+	tagFieldSeparator = "\x1e"
+	tagSeparator      = "\x1f"
+)
+
+// timerTagsColumn selects the tags of a timer aliased as t as one string.
+const timerTagsColumn = `(
+			SELECT group_concat(tg.external_id || char(30) || tg.title, char(31))
+			FROM timer_tags tt JOIN tags tg ON tg.id = tt.tag_id
+			WHERE tt.timer_id = t.id)`
+
+// relationsWriter keeps projects, tags and timer links up to date inside a SaveBatch transaction.
+type relationsWriter struct {
+	upsertProject   *sql.Stmt
+	upsertTag       *sql.Stmt
+	selectTimerId   *sql.Stmt
+	selectTimerTags *sql.Stmt
+	deleteTimerTags *sql.Stmt
+	insertTimerTag  *sql.Stmt
+
+	projectIds map[string]int64
+	tagIds     map[string]int64
+}
+
+func newRelationsWriter(tx DB) (*relationsWriter, error) {
+	w := &relationsWriter{
+		projectIds: make(map[string]int64),
+		tagIds:     make(map[string]int64),
+	}
+
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&w.upsertProject, `
+			INSERT INTO projects (external_id, title) VALUES (?, ?)
+			ON CONFLICT(external_id) DO UPDATE SET title = excluded.title
+			RETURNING id`},
+		{&w.upsertTag, `
+			INSERT INTO tags (external_id, title) VALUES (?, ?)
+			ON CONFLICT(external_id) DO UPDATE SET title = excluded.title
+			RETURNING id`},
+		{&w.selectTimerId, `SELECT id FROM timers WHERE external_id = ?`},
+		{&w.selectTimerTags, `SELECT tag_id FROM timer_tags WHERE timer_id = ?`},
+		{&w.deleteTimerTags, `DELETE FROM timer_tags WHERE timer_id = ?`},
+		{&w.insertTimerTag, `INSERT INTO timer_tags (timer_id, tag_id) VALUES (?, ?)`},
+	}
+	for _, s := range statements {
+		stmt, err := tx.Prepare(s.query)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("prepare %q: %w", strings.TrimSpace(s.query), err)
+		}
+		*s.stmt = stmt
+	}
+	return w, nil
+}
+
+func (w *relationsWriter) Close() {
+	for _, stmt := range []*sql.Stmt{w.upsertProject, w.upsertTag, w.selectTimerId, w.selectTimerTags, w.deleteTimerTags, w.insertTimerTag} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// projectId upserts the project once per batch and returns its row id.
+func (w *relationsWriter) projectId(project *models.ProjectModel) (sql.NullInt64, error) {
+	if project == nil {
+		return sql.NullInt64{}, nil
+	}
+	if id, ok := w.projectIds[project.ExternalId]; ok {
+		return sql.NullInt64{Int64: id, Valid: true}, nil
+	}
+
+	var id int64
+	if err := w.upsertProject.QueryRow(project.ExternalId, project.Title).Scan(&id); err != nil {
+		return sql.NullInt64{}, fmt.Errorf("upsert project %s: %w", project.ExternalId, err)
+	}
+	w.projectIds[project.ExternalId] = id
+	return sql.NullInt64{Int64: id, Valid: true}, nil
+}
+
+func (w *relationsWriter) tagId(tag models.TagModel) (int64, error) {
+	if id, ok := w.tagIds[tag.ExternalId]; ok {
+		return id, nil
+	}
+
+	var id int64
+	if err := w.upsertTag.QueryRow(tag.ExternalId, tag.Title).Scan(&id); err != nil {
+		return 0, fmt.Errorf("upsert tag %s: %w", tag.ExternalId, err)
+	}
+	w.tagIds[tag.ExternalId] = id
+	return id, nil
+}
+
+// syncTags replaces the tags of the timer with the given external id and
+// reports whether they were different.
+func (w *relationsWriter) syncTags(externalId string, tags []models.TagModel) (bool, error) {
+	var timerId int64
+	if err := w.selectTimerId.QueryRow(externalId).Scan(&timerId); err != nil {
+		return false, fmt.Errorf("select timer id: %w", err)
+	}
+
+	wanted := make([]int64, 0, len(tags))
+	for _, tag := range tags {
+		id, err := w.tagId(tag)
+		if err != nil {
+			return false, err
+		}
+		if !slices.Contains(wanted, id) {
+			wanted = append(wanted, id)
+		}
+	}
+
+	rows, err := w.selectTimerTags.Query(timerId)
+	if err != nil {
+		return false, fmt.Errorf("select timer tags: %w", err)
+	}
+	var current []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("row scan: %w", err)
+		}
+		current = append(current, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return false, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	slices.Sort(wanted)
+	slices.Sort(current)
+	if slices.Equal(wanted, current) {
+		return false, nil
+	}
+
+	if _, err := w.deleteTimerTags.Exec(timerId); err != nil {
+		return false, fmt.Errorf("delete timer tags: %w", err)
+	}
+	for _, tagId := range wanted {
+		if _, err := w.insertTimerTag.Exec(timerId, tagId); err != nil {
+			return false, fmt.Errorf("insert timer tag: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// fillTimerRelations sets the project and tags selected next to a timer row.
+func fillTimerRelations(t *models.TimerModel, projectExternalId, projectTitle, tags sql.NullString) {
+	if projectExternalId.Valid {
+		t.Project = &models.ProjectModel{ExternalId: projectExternalId.String, Title: projectTitle.String}
+	}
+	if !tags.Valid || tags.String == "" {
+		return
+	}
+	for _, tag := range strings.Split(tags.String, tagSeparator) {
+		externalId, title, _ := strings.Cut(tag, tagFieldSeparator)
+		t.Tags = append(t.Tags, models.TagModel{ExternalId: externalId, Title: title})
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
     "database/sql"
+    "errors"
     "fmt"
     "gomificator/internal/constnats"
     "gomificator/internal/models"
@@ -10,14 +11,16 @@ import (
 
 type RewardsDailyRepository interface {
     LoadByDate(day time.Time) (models.WalletModel, error)
-    ReplaceForDate(day time.Time, daily models.WalletModel) error
+    LoadRules(day time.Time) (*models.DayRulesModel, error) // Возвращает nil, если правила дня не заморожены
+    ReplaceForDate(day time.Time, daily models.WalletModel, rules models.DayRulesModel) error
+    DaysBetween(startDate, endDate time.Time) ([]time.Time, error)
 }
 
 type rewardsDailyRepository struct {
-    db *sql.DB
+    db DB
 }
 
-func NewRewardsDailyRepository(db *sql.DB) RewardsDailyRepository {
+func NewRewardsDailyRepository(db DB) RewardsDailyRepository {
     return &rewardsDailyRepository{db: db}
 }
 
@@ -47,8 +50,22 @@ func (r *rewardsDailyRepository) LoadByDate(day time.Time) (models.WalletModel,
     return out, nil
 }
 
-func (r *rewardsDailyRepository) ReplaceForDate(day time.Time, daily models.WalletModel) error {
-    tx, err := r.db.Begin()
+func (r *rewardsDailyRepository) LoadRules(day time.Time) (*models.DayRulesModel, error) {
+    var rules models.DayRulesModel
+    err := r.db.QueryRow(`SELECT day_type, rules, hash FROM rewards_rules WHERE day = ?`, day.Format(constnats.DateLayout)).
+        Scan(&rules.DayType, &rules.Rules, &rules.Hash)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("query rewards_rules: %w", err)
+    }
+    return &rules, nil
+}
+
+// ReplaceForDate replaces the rewards of the day and freezes the rules they were earned with.
+func (r *rewardsDailyRepository) ReplaceForDate(day time.Time, daily models.WalletModel, rules models.DayRulesModel) error {
+    tx, err := beginTx(r.db)
     if err != nil {
         return fmt.Errorf("begin tx: %w", err)
     }
@@ -70,9 +87,26 @@ func (r *rewardsDailyRepository) ReplaceForDate(day time.Time, daily models.Wall
         }
     }
 
+    _, err = tx.Exec(`
+        INSERT INTO rewards_rules(day, day_type, rules, hash) VALUES(?, ?, ?, ?)
+        ON CONFLICT(day) DO UPDATE SET day_type = excluded.day_type, rules = excluded.rules, hash = excluded.hash`,
+        day.Format(constnats.DateLayout), rules.DayType, rules.Rules, rules.Hash)
+    if err != nil {
+        return fmt.Errorf("upsert rewards_rules: %w", err)
+    }
+
     if err := tx.Commit(); err != nil {
         return fmt.Errorf("commit tx: %w", err)
     }
     return nil
 }
 
+func (r *rewardsDailyRepository) DaysBetween(startDate, endDate time.Time) ([]time.Time, error) {
+    return queryDays(r.db, `
+        SELECT DISTINCT day FROM rewards_daily
+        WHERE day BETWEEN ? AND ?
+        ORDER BY day`,
+        startDate.Format(constnats.DateLayout),
+        endDate.Format(constnats.DateLayout),
+    )
+}
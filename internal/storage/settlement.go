@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"time"
+)
+
+// SettlementRepository keeps the last day whose rewards were settled automatically.
+type SettlementRepository interface {
+	LastSettled() (*time.Time, error) // Возвращает nil, если расчета еще не было
+	SaveLastSettled(day time.Time) error
+}
+
+type settlementRepository struct {
+	db DB
+}
+
+func NewSettlementRepository(db DB) SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+func (r *settlementRepository) LastSettled() (*time.Time, error) {
+	var day string
+	err := r.db.QueryRow("SELECT last_settled FROM settlement WHERE id = 1").Scan(&day)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query settlement: %w", err)
+	}
+
+	lastSettled := parseStoredDate(day)
+	return &lastSettled, nil
+}
+
+func (r *settlementRepository) SaveLastSettled(day time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO settlement (id, last_settled) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET last_settled = excluded.last_settled`,
+		day.Format(constnats.DateLayout))
+	if err != nil {
+		return fmt.Errorf("upsert settlement: %w", err)
+	}
+	return nil
+}
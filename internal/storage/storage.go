@@ -10,11 +10,20 @@ import (
 )
 
 type Storage struct {
-    db *sql.DB
+    db DB
 
     TimersRepo TimerRepository
     WalletRepo WalletRepository
     RewardsRepo RewardsDailyRepository
+    PeriodRewardsRepo PeriodRewardsRepository
+    CompletionsRepo TaskCompletionsRepository
+    ImportedFilesRepo ImportedFilesRepository
+    ImportRunsRepo ImportRunsRepository
+    PendingDaysRepo PendingRewardDaysRepository
+    SettlementRepo SettlementRepository
+    StreaksRepo StreaksRepository
+    PenaltiesRepo PenaltiesRepository
+    XPRepo XPRepository
 }
 
 // NewSqlliteStorage creates a new SQLite storage instance.
@@ -36,11 +45,39 @@ func NewSqlliteStorage() (*Storage, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+    return newStorage(db), nil
+}
+
+// newStorage creates the repositories working on db.
+func newStorage(db DB) *Storage {
     timerRepo := NewTimerRepository(db)
     walletRepo := NewWalletRepository(db)
     rewardsRepo := NewRewardsDailyRepository(db)
+    periodRewardsRepo := NewPeriodRewardsRepository(db)
+    completionsRepo := NewTaskCompletionsRepository(db)
+    importedFilesRepo := NewImportedFilesRepository(db)
+    importRunsRepo := NewImportRunsRepository(db)
+    pendingDaysRepo := NewPendingRewardDaysRepository(db)
+    settlementRepo := NewSettlementRepository(db)
+    streaksRepo := NewStreaksRepository(db)
+    penaltiesRepo := NewPenaltiesRepository(db)
+    xpRepo := NewXPRepository(db)
 
-    return &Storage{db: db, TimersRepo: timerRepo, WalletRepo: walletRepo, RewardsRepo: rewardsRepo}, nil
+    return &Storage{
+        db:                db,
+        TimersRepo:        timerRepo,
+        WalletRepo:        walletRepo,
+        RewardsRepo:       rewardsRepo,
+        PeriodRewardsRepo: periodRewardsRepo,
+        CompletionsRepo:   completionsRepo,
+        ImportedFilesRepo: importedFilesRepo,
+        ImportRunsRepo:    importRunsRepo,
+        PendingDaysRepo:   pendingDaysRepo,
+        SettlementRepo:    settlementRepo,
+        StreaksRepo:       streaksRepo,
+        PenaltiesRepo:     penaltiesRepo,
+        XPRepo:            xpRepo,
+    }
 }
 
 func getDefaultStoragePath() (string, error) {
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// newTestStorage opens a migrated database in a temporary directory.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "data.db")+"?mode=rwc&_fk=1")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatalf("set dialect: %v", err)
+	}
+	strg := newStorage(db)
+	if err := MigrateDb(strg); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	return strg
+}
+
+func testDay(t *testing.T, day string) time.Time {
+	t.Helper()
+	d, err := time.Parse(constnats.DateLayout, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func testTimer(t *testing.T, externalId, day string, minutes int) models.TimerModel {
+	t.Helper()
+	return models.TimerModel{
+		ExternalId:   &externalId,
+		Name:         externalId,
+		SecondsSpent: time.Duration(minutes) * time.Minute,
+		FixatedAt:    testDay(t, day),
+	}
+}
+
+// storedMinutes returns the minutes of the timers not deleted by external id.
+func storedMinutes(t *testing.T, strg *Storage) map[string]int {
+	t.Helper()
+	timers, err := strg.TimersRepo.GetByExternalIdPrefix("")
+	if err != nil {
+		t.Fatalf("load timers: %v", err)
+	}
+	minutes := make(map[string]int, len(timers))
+	for _, timer := range timers {
+		minutes[*timer.ExternalId] = int(timer.SecondsSpent.Minutes())
+	}
+	return minutes
+}
+
+// withProject links timer to the project with the given external id, none if
+// it is empty.
+func withProject(timer models.TimerModel, project string) models.TimerModel {
+	if project != "" {
+		timer.Project = &models.ProjectModel{ExternalId: project, Title: "Project " + project}
+	}
+	return timer
+}
+
+// storedProjects returns the project external ids of the timers with the
+// external ids in want, empty for timers without a project.
+func storedProjects(t *testing.T, strg *Storage, want map[string]string) map[string]string {
+	t.Helper()
+	timers, err := strg.TimersRepo.GetByExternalIds(slices.Collect(maps.Keys(want)))
+	if err != nil {
+		t.Fatalf("load timers: %v", err)
+	}
+	projects := make(map[string]string, len(timers))
+	for externalId, timer := range timers {
+		projects[externalId] = ""
+		if timer.Project != nil {
+			projects[externalId] = timer.Project.ExternalId
+		}
+	}
+	return projects
+}
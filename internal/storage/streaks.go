@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"time"
+)
+
+// Items kept in the inventory.
+const (
+	ItemStreakFreeze = "streak_freeze"
+)
+
+// StreaksRepository keeps the streak status of settled days, the streak
+// milestones granted so far and the items bought with medals.
+type StreaksRepository interface {
+	Statuses() ([]models.DayStatusModel, error) // По возрастанию дня
+	Status(day time.Time) (string, error)       // Пустая строка, если день не рассчитан
+	SaveStatus(day time.Time, status string) error
+	Rewards() ([]models.StreakRewardModel, error)
+	ReplaceRewards(rewards []models.StreakRewardModel) error
+	Items(item string) (int, error)
+	AddItems(item string, count int) error // Отрицательный count расходует предметы
+}
+
+type streaksRepository struct {
+	db DB
+}
+
+func NewStreaksRepository(db DB) StreaksRepository {
+	return &streaksRepository{db: db}
+}
+
+func (r *streaksRepository) Statuses() ([]models.DayStatusModel, error) {
+	rows, err := r.db.Query("SELECT day, status FROM streak_days ORDER BY day")
+	if err != nil {
+		return nil, fmt.Errorf("query streak days: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []models.DayStatusModel
+	for rows.Next() {
+		var day string
+		var status models.DayStatusModel
+		if err := rows.Scan(&day, &status.Status); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		status.Day = parseStoredDate(day)
+		statuses = append(statuses, status)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return statuses, nil
+}
+
+func (r *streaksRepository) Status(day time.Time) (string, error) {
+	var status string
+	err := r.db.QueryRow("SELECT status FROM streak_days WHERE day = ?", day.Format(constnats.DateLayout)).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query streak day: %w", err)
+	}
+	return status, nil
+}
+
+func (r *streaksRepository) SaveStatus(day time.Time, status string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO streak_days (day, status) VALUES (?, ?)
+		ON CONFLICT(day) DO UPDATE SET status = excluded.status`,
+		day.Format(constnats.DateLayout), status)
+	if err != nil {
+		return fmt.Errorf("upsert streak day: %w", err)
+	}
+	return nil
+}
+
+func (r *streaksRepository) Rewards() ([]models.StreakRewardModel, error) {
+	rows, err := r.db.Query("SELECT streak_start, days, medal_type, count FROM streak_rewards ORDER BY streak_start, days")
+	if err != nil {
+		return nil, fmt.Errorf("query streak rewards: %w", err)
+	}
+	defer rows.Close()
+
+	var rewards []models.StreakRewardModel
+	for rows.Next() {
+		var start, medal string
+		var reward models.StreakRewardModel
+		if err := rows.Scan(&start, &reward.Days, &medal, &reward.Count); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		reward.Start = parseStoredDate(start)
+		reward.Medal, err = constnats.LoadMedal(medal)
+		if err != nil {
+			return nil, fmt.Errorf("load medal: %w", err)
+		}
+		rewards = append(rewards, reward)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return rewards, nil
+}
+
+func (r *streaksRepository) ReplaceRewards(rewards []models.StreakRewardModel) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM streak_rewards"); err != nil {
+		return fmt.Errorf("delete old streak rewards: %w", err)
+	}
+	for _, reward := range rewards {
+		_, err := tx.Exec("INSERT INTO streak_rewards (streak_start, days, medal_type, count) VALUES (?, ?, ?, ?)",
+			reward.Start.Format(constnats.DateLayout), reward.Days, string(reward.Medal), reward.Count)
+		if err != nil {
+			return fmt.Errorf("insert streak reward: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *streaksRepository) Items(item string) (int, error) {
+	var cnt int
+	err := r.db.QueryRow("SELECT count FROM inventory WHERE item = ?", item).Scan(&cnt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query inventory: %w", err)
+	}
+	return cnt, nil
+}
+
+func (r *streaksRepository) AddItems(item string, count int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO inventory (item, count) VALUES (?, ?)
+		ON CONFLICT(item) DO UPDATE SET count = count + excluded.count`,
+		item, count)
+	if err != nil {
+		return fmt.Errorf("upsert inventory: %w", err)
+	}
+	return nil
+}
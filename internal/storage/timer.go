@@ -5,49 +5,267 @@ import (
 	"fmt"
 	"gomificator/internal/constnats"
 	"gomificator/internal/models"
+	"strings"
 	"time"
 )
 
+// externalIdsChunkSize keeps IN (...) lists well below SQLite's variable limit.
+const externalIdsChunkSize = 500
+
 type TimerRepository interface {
 	Save(timer models.TimerModel) (int, error) // Создает новый, если id == nil или обновляет нужную запись
+	SaveBatch(timers []models.TimerModel) (SaveBatchResult, error)
+	SaveBatchInRun(runId int, timers []models.TimerModel) (SaveBatchResult, error) // Запоминает прежние значения для import undo
 	GetLastTimers(q int) ([]models.TimerModel, error)
 	GetTimersBetweenDates(startDate, endDate time.Time) ([]models.TimerModel, error)
+	GetDaysBetweenDates(startDate, endDate time.Time) ([]time.Time, error)       // Дни, в которые есть таймеры
+	GetFirstDate() (*time.Time, error)                                           // Возвращает nil, если таймеров нет
+	GetByExternalIds(externalIds []string) (map[string]models.TimerModel, error) // Включает мягко удаленные записи
+	GetByExternalIdPrefix(prefix string) ([]models.TimerModel, error)
 	Delete(id int) error
+	DeleteMany(ids []int, soft bool) error
+	DeleteManyInRun(runId int, ids []int, soft bool) error
+}
+
+// SaveBatchResult counts what SaveBatch did with the given timers.
+type SaveBatchResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
 }
 
 type timerRepository struct {
-	db *sql.DB
+	db DB
 }
 
-func NewTimerRepository(db *sql.DB) TimerRepository {
+func NewTimerRepository(db DB) TimerRepository {
 	return &timerRepository{db: db}
 }
 
 func (r *timerRepository) Save(timer models.TimerModel) (int, error) {
-	if timer.Id != nil {
-		return r.update(timer)
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	relations, err := newRelationsWriter(tx)
+	if err != nil {
+		return 0, fmt.Errorf("new relations writer: %w", err)
+	}
+	defer relations.Close()
+	projectId, err := relations.projectId(timer.Project)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	switch {
+	case timer.Id != nil:
+		id, err = updateTimer(tx, timer, projectId)
+	case timer.ExternalId != nil:
+		knownId, lookupErr := timerIdByExternalId(tx, *timer.ExternalId)
+		if lookupErr != nil {
+			id, err = createTimer(tx, timer, projectId)
+			break
+		}
+		timer.Id = &knownId
+		id, err = updateTimer(tx, timer, projectId)
+	default:
+		id, err = createTimer(tx, timer, projectId)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return id, nil
+}
+
+// SaveBatch upserts timers by external id in a single transaction. Rows whose
+// values didn't change are left untouched and counted as unchanged.
+func (r *timerRepository) SaveBatch(timers []models.TimerModel) (SaveBatchResult, error) {
+	return r.saveBatch(timers, nil)
+}
+
+func (r *timerRepository) SaveBatchInRun(runId int, timers []models.TimerModel) (SaveBatchResult, error) {
+	return r.saveBatch(timers, &runId)
+}
+
+func (r *timerRepository) saveBatch(timers []models.TimerModel, runId *int) (SaveBatchResult, error) {
+	var result SaveBatchResult
+
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return result, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	known, err := loadExternalIds(tx)
+	if err != nil {
+		return result, fmt.Errorf("load external ids: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO timers (external_id, fixed_at, seconds_spent, ended_at, name, description, parent_external_id, project_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(external_id) DO UPDATE SET
+			fixed_at = excluded.fixed_at,
+			seconds_spent = excluded.seconds_spent,
+			ended_at = excluded.ended_at,
+			name = excluded.name,
+			description = excluded.description,
+			parent_external_id = excluded.parent_external_id,
+			project_id = excluded.project_id,
+			deleted_at = NULL
+		WHERE timers.fixed_at IS NOT excluded.fixed_at
+			OR timers.seconds_spent IS NOT excluded.seconds_spent
+			OR timers.ended_at IS NOT excluded.ended_at
+			OR timers.name IS NOT excluded.name
+			OR timers.description IS NOT excluded.description
+			OR timers.parent_external_id IS NOT excluded.parent_external_id
+			OR timers.project_id IS NOT excluded.project_id
+			OR timers.deleted_at IS NOT NULL`)
+	if err != nil {
+		return result, fmt.Errorf("prepare upsert: %w", err)
 	}
-	if timer.Id == nil && timer.ExternalId != nil {
-		id, err := r.getIdByExternalId(*timer.ExternalId)
+	defer stmt.Close()
+
+	relations, err := newRelationsWriter(tx)
+	if err != nil {
+		return result, fmt.Errorf("new relations writer: %w", err)
+	}
+	defer relations.Close()
+
+	var changes *runChangesWriter
+	if runId != nil {
+		changes, err = newRunChangesWriter(tx, *runId)
 		if err != nil {
-			return r.create(timer)
+			return result, fmt.Errorf("new run changes writer: %w", err)
 		}
-		timer.Id = &id
-		return r.update(timer)
+		defer changes.Close()
 	}
-	return r.create(timer)
+
+	for _, t := range timers {
+		var externalId sql.NullString
+		if t.ExternalId != nil {
+			externalId = sql.NullString{String: *t.ExternalId, Valid: true}
+		}
+
+		projectId, err := relations.projectId(t.Project)
+		if err != nil {
+			return result, err
+		}
+
+		day := t.FixatedAt.Format(constnats.DateLayout)
+		timerId, existed := known[externalId.String]
+		existed = existed && externalId.Valid
+		var changeId int64
+		if changes != nil && existed {
+			if changeId, err = changes.before(timerId, runChangeUpdate, &day); err != nil {
+				return result, err
+			}
+		}
+
+		res, err := stmt.Exec(
+			externalId,
+			day,
+			int(t.SecondsSpent.Seconds()),
+			storedEndedAt(t.EndedAt),
+			t.Name,
+			t.Description,
+			t.ParentExternalId,
+			projectId,
+		)
+		if err != nil {
+			return result, fmt.Errorf("upsert timer %s: %w", externalId.String, err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return result, fmt.Errorf("rows affected: %w", err)
+		}
+
+		if t.Tags != nil && externalId.Valid {
+			tagsChanged, err := relations.syncTags(externalId.String, t.Tags)
+			if err != nil {
+				return result, fmt.Errorf("sync tags of %s: %w", externalId.String, err)
+			}
+			if tagsChanged {
+				affected = 1
+			}
+		}
+
+		switch {
+		case !existed:
+			result.Inserted++
+			insertedId, err := res.LastInsertId()
+			if err != nil {
+				return result, fmt.Errorf("last insert id: %w", err)
+			}
+			if externalId.Valid {
+				known[externalId.String] = int(insertedId)
+			}
+			if changes != nil {
+				if err := changes.inserted(insertedId, day, externalId); err != nil {
+					return result, err
+				}
+			}
+		case affected == 0:
+			result.Unchanged++
+			if changes != nil {
+				if err := changes.forget(changeId); err != nil {
+					return result, err
+				}
+			}
+		default:
+			result.Updated++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("commit tx: %w", err)
+	}
+	return result, nil
 }
 
-func (r *timerRepository) create(t models.TimerModel) (int, error) {
+// loadExternalIds maps external ids of stored timers to their ids.
+func loadExternalIds(tx DB) (map[string]int, error) {
+	rows, err := tx.Query("SELECT external_id, id FROM timers WHERE external_id IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
 
-	res, err := r.db.Exec(`
-		INSERT INTO timers (external_id, fixed_at, seconds_spent, name, description)
-		VALUES (?, ?, ?, ?, ?)`,
+	ids := make(map[string]int)
+	for rows.Next() {
+		var externalId string
+		var id int
+		if err := rows.Scan(&externalId, &id); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		ids[externalId] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return ids, nil
+}
+
+func createTimer(tx DB, t models.TimerModel, projectId sql.NullInt64) (int, error) {
+
+	res, err := tx.Exec(`
+		INSERT INTO timers (external_id, fixed_at, seconds_spent, ended_at, name, description, parent_external_id, project_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
 		*t.ExternalId,
 		t.FixatedAt.Format("2006-01-02"),
 		int(t.SecondsSpent.Seconds()),
+		storedEndedAt(t.EndedAt),
 		t.Name,
 		t.Description,
+		t.ParentExternalId,
+		projectId,
 	)
 	if err != nil {
 		return 0, err
@@ -57,30 +275,37 @@ func (r *timerRepository) create(t models.TimerModel) (int, error) {
 	return int(id), err
 }
 
-func (r *timerRepository) update(t models.TimerModel) (int, error) {
-	_, err := r.db.Exec(`
+func updateTimer(tx DB, t models.TimerModel, projectId sql.NullInt64) (int, error) {
+	_, err := tx.Exec(`
 		UPDATE timers
 		SET external_id = ?,
 			fixed_at = ?,
 			seconds_spent = ?,
+			ended_at = ?,
 			name = ?,
-			description = ?
+			description = ?,
+			parent_external_id = ?,
+			project_id = ?,
+			deleted_at = NULL
 		WHERE id = ?`,
 		*t.ExternalId,
 		t.FixatedAt.Format("2006-01-02"),
 		int(t.SecondsSpent.Seconds()),
+		storedEndedAt(t.EndedAt),
 		t.Name,
 		t.Description,
+		t.ParentExternalId,
+		projectId,
 		*t.Id,
 	)
 	return *t.Id, err
 }
 
-func (r *timerRepository) getIdByExternalId(externalId string) (int, error) {
+func timerIdByExternalId(tx DB, externalId string) (int, error) {
 	query := "SELECT id FROM timers WHERE external_id = ? limit 1"
 
 	var id int
-	err := r.db.QueryRow(
+	err := tx.QueryRow(
 		query,
 		externalId,
 	).Scan(&id)
@@ -91,6 +316,7 @@ func (r *timerRepository) GetLastTimers(q int) ([]models.TimerModel, error) {
 	rows, err := r.db.Query(`
 		SELECT id, external_id, fixed_at, seconds_spent, name, description, created_at
 		FROM timers
+		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT ?`, q)
 	if err != nil {
@@ -122,7 +348,7 @@ func (r *timerRepository) GetLastTimers(q int) ([]models.TimerModel, error) {
 		if externalId.Valid {
 			t.ExternalId = &externalId.String
 		}
-		t.FixatedAt, _ = time.Parse(constnats.DateLayout, fixatedAtStr)
+		t.FixatedAt = parseStoredDate(fixatedAtStr)
 		t.SecondsSpent = time.Duration(secondsSpent) * time.Second
 		t.CreatedAt = &createdAt
 
@@ -132,15 +358,126 @@ func (r *timerRepository) GetLastTimers(q int) ([]models.TimerModel, error) {
 }
 
 func (r *timerRepository) Delete(id int) error {
+	if _, err := r.db.Exec("DELETE FROM timer_tags WHERE timer_id = ?", id); err != nil {
+		return err
+	}
 	_, err := r.db.Exec("DELETE FROM timers WHERE id = ?", id)
 	return err
 }
 
-func (r *timerRepository) GetTimersBetweenDates(startDate, endDate time.Time) ([]models.TimerModel, error) {
+// DeleteMany removes timers in one transaction. Soft deletion only marks them
+// with deleted_at, so saving the same external id again brings them back.
+func (r *timerRepository) DeleteMany(ids []int, soft bool) error {
+	return r.deleteMany(ids, soft, nil)
+}
+
+func (r *timerRepository) DeleteManyInRun(runId int, ids []int, soft bool) error {
+	return r.deleteMany(ids, soft, &runId)
+}
+
+func (r *timerRepository) deleteMany(ids []int, soft bool, runId *int) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var changes *runChangesWriter
+	if runId != nil {
+		changes, err = newRunChangesWriter(tx, *runId)
+		if err != nil {
+			return fmt.Errorf("new run changes writer: %w", err)
+		}
+		defer changes.Close()
+	}
+
+	query := "DELETE FROM timers WHERE id = ?"
+	if soft {
+		query = "UPDATE timers SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?"
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		if changes != nil {
+			if _, err := changes.before(id, runChangeDelete, nil); err != nil {
+				return err
+			}
+		}
+		if !soft {
+			if _, err := tx.Exec("DELETE FROM timer_tags WHERE timer_id = ?", id); err != nil {
+				return fmt.Errorf("delete tags of timer %d: %w", id, err)
+			}
+		}
+		if _, err := stmt.Exec(id); err != nil {
+			return fmt.Errorf("delete timer %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *timerRepository) GetByExternalIdPrefix(prefix string) ([]models.TimerModel, error) {
 	rows, err := r.db.Query(`
 		SELECT id, external_id, fixed_at, seconds_spent, name, description, created_at
 		FROM timers
-		WHERE fixed_at BETWEEN ? AND ?`,
+		WHERE substr(external_id, 1, ?) = ? AND deleted_at IS NULL`,
+		len(prefix),
+		prefix,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var timers []models.TimerModel
+	for rows.Next() {
+		var t models.TimerModel
+		var fixatedAtStr string
+		var secondsSpent int
+		var externalId sql.NullString
+		var createdAt time.Time
+
+		err := rows.Scan(
+			&t.Id,
+			&externalId,
+			&fixatedAtStr,
+			&secondsSpent,
+			&t.Name,
+			&t.Description,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+
+		t.ExternalId = &externalId.String
+		t.CreatedAt = &createdAt
+		t.FixatedAt = parseStoredDate(fixatedAtStr)
+		t.SecondsSpent = time.Duration(secondsSpent) * time.Second
+
+		timers = append(timers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return timers, nil
+}
+
+func (r *timerRepository) GetTimersBetweenDates(startDate, endDate time.Time) ([]models.TimerModel, error) {
+	rows, err := r.db.Query(`
+		SELECT t.id, t.external_id, t.fixed_at, t.seconds_spent, t.ended_at, t.name, t.description, t.created_at,
+			p.external_id, p.title, `+timerTagsColumn+`
+		FROM timers t
+		LEFT JOIN projects p ON p.id = t.project_id
+		WHERE t.fixed_at BETWEEN ? AND ? AND t.deleted_at IS NULL`,
 		startDate.Format(constnats.DateLayout),
 		endDate.Format(constnats.DateLayout),
 	)
@@ -156,16 +493,22 @@ func (r *timerRepository) GetTimersBetweenDates(startDate, endDate time.Time) ([
 		var fixatedAtStr string
 		var secondsSpent int
 		var externalId sql.NullString
+		var endedAt sql.NullTime
 		var createdAt time.Time
+		var projectExternalId, projectTitle, tags sql.NullString
 
 		err := rows.Scan(
 			&t.Id,
 			&externalId,
 			&fixatedAtStr,
 			&secondsSpent,
+			&endedAt,
 			&t.Name,
 			&t.Description,
 			&createdAt,
+			&projectExternalId,
+			&projectTitle,
+			&tags,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("row scan: %w", err)
@@ -174,10 +517,14 @@ func (r *timerRepository) GetTimersBetweenDates(startDate, endDate time.Time) ([
 		if externalId.Valid {
 			t.ExternalId = &externalId.String
 		}
+		fillTimerRelations(&t, projectExternalId, projectTitle, tags)
 
 		t.CreatedAt = &createdAt
-		t.FixatedAt, _ = time.Parse(constnats.DateLayout, fixatedAtStr)
+		t.FixatedAt = parseStoredDate(fixatedAtStr)
 		t.SecondsSpent = time.Duration(secondsSpent) * time.Second
+		if endedAt.Valid {
+			t.EndedAt = &endedAt.Time
+		}
 
 		timers = append(timers, t)
 
@@ -185,3 +532,148 @@ func (r *timerRepository) GetTimersBetweenDates(startDate, endDate time.Time) ([
 
 	return timers, nil
 }
+
+func (r *timerRepository) GetDaysBetweenDates(startDate, endDate time.Time) ([]time.Time, error) {
+	return queryDays(r.db, `
+		SELECT DISTINCT fixed_at FROM timers
+		WHERE fixed_at BETWEEN ? AND ? AND deleted_at IS NULL
+		ORDER BY fixed_at`,
+		startDate.Format(constnats.DateLayout),
+		endDate.Format(constnats.DateLayout),
+	)
+}
+
+func (r *timerRepository) GetFirstDate() (*time.Time, error) {
+	var day sql.NullString
+	err := r.db.QueryRow("SELECT min(fixed_at) FROM timers WHERE deleted_at IS NULL").Scan(&day)
+	if err != nil {
+		return nil, fmt.Errorf("query first timer date: %w", err)
+	}
+	if !day.Valid {
+		return nil, nil
+	}
+
+	first := parseStoredDate(day.String)
+	return &first, nil
+}
+
+func (r *timerRepository) GetByExternalIds(externalIds []string) (map[string]models.TimerModel, error) {
+	out := make(map[string]models.TimerModel, len(externalIds))
+
+	for start := 0; start < len(externalIds); start += externalIdsChunkSize {
+		chunk := externalIds[start:min(start+externalIdsChunkSize, len(externalIds))]
+
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+
+		rows, err := r.db.Query(`
+		SELECT t.id, t.external_id, t.fixed_at, t.seconds_spent, t.ended_at, t.name, t.description, t.created_at, t.deleted_at, t.parent_external_id,
+			p.external_id, p.title, `+timerTagsColumn+`
+		FROM timers t
+		LEFT JOIN projects p ON p.id = t.project_id
+		WHERE t.external_id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query: %w", err)
+		}
+
+		for rows.Next() {
+			var t models.TimerModel
+			var fixatedAtStr string
+			var secondsSpent int
+			var externalId sql.NullString
+			var endedAt sql.NullTime
+			var createdAt time.Time
+			var deletedAt sql.NullTime
+			var parentExternalId sql.NullString
+			var projectExternalId, projectTitle, tags sql.NullString
+
+			err := rows.Scan(
+				&t.Id,
+				&externalId,
+				&fixatedAtStr,
+				&secondsSpent,
+				&endedAt,
+				&t.Name,
+				&t.Description,
+				&createdAt,
+				&deletedAt,
+				&parentExternalId,
+				&projectExternalId,
+				&projectTitle,
+				&tags,
+			)
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("row scan: %w", err)
+			}
+
+			t.ExternalId = &externalId.String
+			t.CreatedAt = &createdAt
+			if deletedAt.Valid {
+				t.DeletedAt = &deletedAt.Time
+			}
+			if parentExternalId.Valid {
+				t.ParentExternalId = &parentExternalId.String
+			}
+			fillTimerRelations(&t, projectExternalId, projectTitle, tags)
+			t.FixatedAt = parseStoredDate(fixatedAtStr)
+			t.SecondsSpent = time.Duration(secondsSpent) * time.Second
+			if endedAt.Valid {
+				t.EndedAt = &endedAt.Time
+			}
+
+			out[externalId.String] = t
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate rows: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+// parseStoredDate parses a fixed_at value. The driver hands DATE columns back as
+// RFC 3339 timestamps, so only the date part is taken into account.
+func parseStoredDate(value string) time.Time {
+	if len(value) > len(constnats.DateLayout) {
+		value = value[:len(constnats.DateLayout)]
+	}
+	date, _ := time.Parse(constnats.DateLayout, value)
+	return date
+}
+
+// storedEndedAt normalizes an end time to whole seconds in UTC, so the same
+// instant imported again compares equal to the stored one.
+func storedEndedAt(endedAt *time.Time) sql.NullTime {
+	if endedAt == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: endedAt.UTC().Truncate(time.Second), Valid: true}
+}
+
+// queryDays runs a query selecting a single date column.
+func queryDays(db DB, query string, args ...any) ([]time.Time, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query days: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		days = append(days, parseStoredDate(day))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return days, nil
+}
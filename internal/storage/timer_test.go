@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"gomificator/internal/models"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestSaveBatch(t *testing.T) {
+	type timer struct {
+		id      string
+		day     string
+		minutes int
+	}
+
+	tests := []struct {
+		name        string
+		stored      []timer
+		softDeleted []string
+		batch       []timer
+		// projects link timers of stored and batch to projects by their ids
+		storedProjects map[string]string
+		batchProjects  map[string]string
+		want           SaveBatchResult
+		wantMinutes    map[string]int
+		wantProjects   map[string]string
+	}{
+		{
+			name:        "new timers are inserted",
+			batch:       []timer{{"a", "2025-11-10", 30}, {"b", "2025-11-11", 45}},
+			want:        SaveBatchResult{Inserted: 2},
+			wantMinutes: map[string]int{"a": 30, "b": 45},
+		},
+		{
+			name:        "same values are unchanged",
+			stored:      []timer{{"a", "2025-11-10", 30}},
+			batch:       []timer{{"a", "2025-11-10", 30}},
+			want:        SaveBatchResult{Unchanged: 1},
+			wantMinutes: map[string]int{"a": 30},
+		},
+		{
+			name:        "changed values are updated",
+			stored:      []timer{{"a", "2025-11-10", 30}, {"b", "2025-11-10", 10}},
+			batch:       []timer{{"a", "2025-11-10", 40}, {"b", "2025-11-11", 10}},
+			want:        SaveBatchResult{Updated: 2},
+			wantMinutes: map[string]int{"a": 40, "b": 10},
+		},
+		{
+			name:        "mixed batch",
+			stored:      []timer{{"a", "2025-11-10", 30}, {"b", "2025-11-10", 10}},
+			batch:       []timer{{"a", "2025-11-10", 30}, {"b", "2025-11-10", 20}, {"c", "2025-11-10", 5}},
+			want:        SaveBatchResult{Inserted: 1, Updated: 1, Unchanged: 1},
+			wantMinutes: map[string]int{"a": 30, "b": 20, "c": 5},
+		},
+		{
+			name:        "soft deleted timer comes back",
+			stored:      []timer{{"a", "2025-11-10", 30}, {"b", "2025-11-10", 10}},
+			softDeleted: []string{"a"},
+			batch:       []timer{{"a", "2025-11-10", 30}},
+			want:        SaveBatchResult{Updated: 1},
+			wantMinutes: map[string]int{"a": 30, "b": 10},
+		},
+		{
+			name:        "duplicate ids in one batch are saved once",
+			batch:       []timer{{"a", "2025-11-10", 30}, {"a", "2025-11-10", 35}},
+			want:        SaveBatchResult{Inserted: 1, Updated: 1},
+			wantMinutes: map[string]int{"a": 35},
+		},
+		{
+			name:          "project is linked on insert",
+			batch:         []timer{{"a", "2025-11-10", 30}, {"b", "2025-11-10", 10}},
+			batchProjects: map[string]string{"a": "p1"},
+			want:          SaveBatchResult{Inserted: 2},
+			wantMinutes:   map[string]int{"a": 30, "b": 10},
+			wantProjects:  map[string]string{"a": "p1", "b": ""},
+		},
+		{
+			name:           "changed project is updated",
+			stored:         []timer{{"a", "2025-11-10", 30}},
+			storedProjects: map[string]string{"a": "p1"},
+			batch:          []timer{{"a", "2025-11-10", 30}},
+			batchProjects:  map[string]string{"a": "p2"},
+			want:           SaveBatchResult{Updated: 1},
+			wantMinutes:    map[string]int{"a": 30},
+			wantProjects:   map[string]string{"a": "p2"},
+		},
+		{
+			name:           "removed project is unlinked",
+			stored:         []timer{{"a", "2025-11-10", 30}},
+			storedProjects: map[string]string{"a": "p1"},
+			batch:          []timer{{"a", "2025-11-10", 30}},
+			want:           SaveBatchResult{Updated: 1},
+			wantMinutes:    map[string]int{"a": 30},
+			wantProjects:   map[string]string{"a": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := newTestStorage(t)
+			toModels := func(timers []timer, projects map[string]string) []models.TimerModel {
+				out := make([]models.TimerModel, 0, len(timers))
+				for _, tm := range timers {
+					out = append(out, withProject(testTimer(t, tm.id, tm.day, tm.minutes), projects[tm.id]))
+				}
+				return out
+			}
+
+			if _, err := strg.TimersRepo.SaveBatch(toModels(tt.stored, tt.storedProjects)); err != nil {
+				t.Fatalf("seed timers: %v", err)
+			}
+			if len(tt.softDeleted) > 0 {
+				stored, err := strg.TimersRepo.GetByExternalIds(tt.softDeleted)
+				if err != nil {
+					t.Fatalf("load timers: %v", err)
+				}
+				var ids []int
+				for _, timer := range stored {
+					ids = append(ids, *timer.Id)
+				}
+				if err := strg.TimersRepo.DeleteMany(ids, true); err != nil {
+					t.Fatalf("soft delete: %v", err)
+				}
+			}
+
+			got, err := strg.TimersRepo.SaveBatch(toModels(tt.batch, tt.batchProjects))
+			if err != nil {
+				t.Fatalf("SaveBatch() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SaveBatch() = %+v, want %+v", got, tt.want)
+			}
+			if minutes := storedMinutes(t, strg); !maps.Equal(minutes, tt.wantMinutes) {
+				t.Errorf("stored minutes = %v, want %v", minutes, tt.wantMinutes)
+			}
+			if tt.wantProjects != nil {
+				if projects := storedProjects(t, strg, tt.wantProjects); !maps.Equal(projects, tt.wantProjects) {
+					t.Errorf("stored projects = %v, want %v", projects, tt.wantProjects)
+				}
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name string
+		// stored is saved before timer, both link to the project of their id
+		stored       map[string]string
+		timer        models.TimerModel
+		byId         bool
+		wantProjects map[string]string
+	}{
+		{
+			name:         "new timer links its project",
+			timer:        withProject(testTimer(t, "a", "2025-11-10", 30), "p1"),
+			wantProjects: map[string]string{"a": "p1"},
+		},
+		{
+			name:         "timer found by external id changes its project",
+			stored:       map[string]string{"a": "p1"},
+			timer:        withProject(testTimer(t, "a", "2025-11-10", 30), "p2"),
+			wantProjects: map[string]string{"a": "p2"},
+		},
+		{
+			name:         "timer saved by id changes its project",
+			stored:       map[string]string{"a": "p1"},
+			timer:        withProject(testTimer(t, "a", "2025-11-10", 30), "p2"),
+			byId:         true,
+			wantProjects: map[string]string{"a": "p2"},
+		},
+		{
+			name:         "timer without a project unlinks it",
+			stored:       map[string]string{"a": "p1"},
+			timer:        testTimer(t, "a", "2025-11-10", 30),
+			wantProjects: map[string]string{"a": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := newTestStorage(t)
+			for externalId, project := range tt.stored {
+				if _, err := strg.TimersRepo.Save(withProject(testTimer(t, externalId, "2025-11-09", 10), project)); err != nil {
+					t.Fatalf("seed timer: %v", err)
+				}
+			}
+
+			timer := tt.timer
+			if tt.byId {
+				stored, err := strg.TimersRepo.GetByExternalIds([]string{*timer.ExternalId})
+				if err != nil {
+					t.Fatalf("load timer: %v", err)
+				}
+				timer.Id = stored[*timer.ExternalId].Id
+			}
+			if _, err := strg.TimersRepo.Save(timer); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			if projects := storedProjects(t, strg, tt.wantProjects); !maps.Equal(projects, tt.wantProjects) {
+				t.Errorf("stored projects = %v, want %v", projects, tt.wantProjects)
+			}
+			if minutes := storedMinutes(t, strg); minutes[*timer.ExternalId] != 30 {
+				t.Errorf("stored minutes = %v, want 30", minutes[*timer.ExternalId])
+			}
+		})
+	}
+}
+
+func TestReconcileDeletes(t *testing.T) {
+	stored := []string{"Org:/a.org:x", "Org:/a.org:y", "Org:/b.org:x", "WakaTime:p:2025-11-10"}
+
+	tests := []struct {
+		name        string
+		prefix      string
+		incoming    []string
+		soft        bool
+		wantPrefix  []string
+		wantMinutes map[string]int
+		// wantKept are the ids GetByExternalIds still finds, soft deleted
+		// rows included
+		wantKept int
+	}{
+		{
+			name:        "prefix is scoped to the file",
+			prefix:      "Org:/a.org:",
+			incoming:    []string{"Org:/a.org:x"},
+			soft:        true,
+			wantPrefix:  []string{"Org:/a.org:x", "Org:/a.org:y"},
+			wantMinutes: map[string]int{"Org:/a.org:x": 10, "Org:/b.org:x": 10, "WakaTime:p:2025-11-10": 10},
+			wantKept:    4,
+		},
+		{
+			name:        "hard delete removes the rows",
+			prefix:      "Org:/a.org:",
+			incoming:    nil,
+			soft:        false,
+			wantPrefix:  []string{"Org:/a.org:x", "Org:/a.org:y"},
+			wantMinutes: map[string]int{"Org:/b.org:x": 10, "WakaTime:p:2025-11-10": 10},
+			wantKept:    2,
+		},
+		{
+			name:        "nothing missing",
+			prefix:      "WakaTime:",
+			incoming:    []string{"WakaTime:p:2025-11-10"},
+			soft:        true,
+			wantPrefix:  []string{"WakaTime:p:2025-11-10"},
+			wantMinutes: map[string]int{"Org:/a.org:x": 10, "Org:/a.org:y": 10, "Org:/b.org:x": 10, "WakaTime:p:2025-11-10": 10},
+			wantKept:    4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := newTestStorage(t)
+			var seed []models.TimerModel
+			for _, id := range stored {
+				seed = append(seed, testTimer(t, id, "2025-11-10", 10))
+			}
+			if _, err := strg.TimersRepo.SaveBatch(seed); err != nil {
+				t.Fatalf("seed timers: %v", err)
+			}
+
+			byPrefix, err := strg.TimersRepo.GetByExternalIdPrefix(tt.prefix)
+			if err != nil {
+				t.Fatalf("GetByExternalIdPrefix() error = %v", err)
+			}
+			var gotPrefix []string
+			var missing []int
+			for _, timer := range byPrefix {
+				gotPrefix = append(gotPrefix, *timer.ExternalId)
+				if !slices.Contains(tt.incoming, *timer.ExternalId) {
+					missing = append(missing, *timer.Id)
+				}
+			}
+			slices.Sort(gotPrefix)
+			if !slices.Equal(gotPrefix, tt.wantPrefix) {
+				t.Errorf("GetByExternalIdPrefix() = %v, want %v", gotPrefix, tt.wantPrefix)
+			}
+
+			if err := strg.TimersRepo.DeleteMany(missing, tt.soft); err != nil {
+				t.Fatalf("DeleteMany() error = %v", err)
+			}
+			if minutes := storedMinutes(t, strg); !maps.Equal(minutes, tt.wantMinutes) {
+				t.Errorf("stored minutes = %v, want %v", minutes, tt.wantMinutes)
+			}
+			kept, err := strg.TimersRepo.GetByExternalIds(stored)
+			if err != nil {
+				t.Fatalf("GetByExternalIds() error = %v", err)
+			}
+			if len(kept) != tt.wantKept {
+				t.Errorf("GetByExternalIds() found %d timers, want %d", len(kept), tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DB is what the repositories need of a connection, *sql.DB and *sql.Tx
+// both are one.
+type DB interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// repoTx is the transaction a repository method writes in. Inside InTx it is
+// the transaction of InTx, Commit and Rollback are then left to InTx.
+type repoTx struct {
+	*sql.Tx
+	owned bool
+}
+
+func beginTx(db DB) (repoTx, error) {
+	switch db := db.(type) {
+	case *sql.Tx:
+		return repoTx{Tx: db}, nil
+	case *sql.DB:
+		tx, err := db.Begin()
+		if err != nil {
+			return repoTx{}, err
+		}
+		return repoTx{Tx: tx, owned: true}, nil
+	default:
+		return repoTx{}, fmt.Errorf("unsupported connection %T", db)
+	}
+}
+
+func (t repoTx) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t repoTx) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// InTx runs fn with repositories that share one transaction, nothing fn
+// writes is saved if it returns an error. Calls inside fn join the
+// transaction.
+func (s *Storage) InTx(fn func(tx *Storage) error) error {
+	db, ok := s.db.(*sql.DB)
+	if !ok {
+		return fn(s)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(newStorage(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
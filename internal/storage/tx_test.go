@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"errors"
+	"gomificator/internal/models"
+	"maps"
+	"testing"
+)
+
+func TestInTx(t *testing.T) {
+	errSettle := errors.New("settle failed")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantWallet  models.WalletModel
+		wantMinutes map[string]int
+	}{
+		{
+			name:        "writes are saved",
+			wantWallet:  models.WalletModel{"bronze": 3},
+			wantMinutes: map[string]int{"a": 30},
+		},
+		{
+			name:        "error rolls back every write",
+			err:         errSettle,
+			wantWallet:  models.WalletModel{"bronze": 1},
+			wantMinutes: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := newTestStorage(t)
+			if err := strg.WalletRepo.Save(models.WalletModel{"bronze": 1}); err != nil {
+				t.Fatalf("seed wallet: %v", err)
+			}
+
+			err := strg.InTx(func(tx *Storage) error {
+				if err := tx.WalletRepo.Save(models.WalletModel{"bronze": 3}); err != nil {
+					return err
+				}
+				// SaveBatch joins the transaction instead of committing its own
+				if _, err := tx.TimersRepo.SaveBatch([]models.TimerModel{testTimer(t, "a", "2025-11-10", 30)}); err != nil {
+					return err
+				}
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("InTx() error = %v, want %v", err, tt.err)
+			}
+
+			wallet, err := strg.WalletRepo.Load()
+			if err != nil {
+				t.Fatalf("load wallet: %v", err)
+			}
+			if !maps.Equal(wallet, tt.wantWallet) {
+				t.Errorf("wallet = %v, want %v", wallet, tt.wantWallet)
+			}
+			if minutes := storedMinutes(t, strg); !maps.Equal(minutes, tt.wantMinutes) {
+				t.Errorf("stored minutes = %v, want %v", minutes, tt.wantMinutes)
+			}
+		})
+	}
+}
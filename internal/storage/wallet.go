@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"database/sql"
 	"fmt"
 	"gomificator/internal/constnats"
 	"gomificator/internal/models"
@@ -13,10 +12,10 @@ type WalletRepository interface {
 }
 
 type walletRepository struct {
-	db *sql.DB
+	db DB
 }
 
-func NewWalletRepository(db *sql.DB) WalletRepository {
+func NewWalletRepository(db DB) WalletRepository {
 	return &walletRepository{db: db}
 }
 
@@ -49,7 +48,7 @@ func (r *walletRepository) Load() (models.WalletModel, error) {
 }
 
 func (r *walletRepository) Save(wallet models.WalletModel) error {
-	tx, err := r.db.Begin()
+	tx, err := beginTx(r.db)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
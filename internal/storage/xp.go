@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"fmt"
+	"gomificator/internal/constnats"
+	"gomificator/internal/models"
+	"time"
+)
+
+// XPRepository keeps the XP events of settled days and the days levels were
+// reached.
+type XPRepository interface {
+	Events() ([]models.XPEventModel, error)                                             // По возрастанию дня
+	ReplaceForDate(day time.Time, sources []string, events []models.XPEventModel) error // Остальные источники дня не трогает
+	ReplaceSource(source string, events []models.XPEventModel) error                    // Заменяет события источника за все дни
+	LevelUps() ([]models.LevelUpModel, error)                                           // По возрастанию уровня
+	ReplaceLevelUps(levelUps []models.LevelUpModel) error
+	BackfillDays() ([]time.Time, error) // Дни, рассчитанные до появления XP
+	RemoveBackfillDays(days []time.Time) error
+}
+
+type xpRepository struct {
+	db DB
+}
+
+func NewXPRepository(db DB) XPRepository {
+	return &xpRepository{db: db}
+}
+
+func (r *xpRepository) Events() ([]models.XPEventModel, error) {
+	rows, err := r.db.Query("SELECT day, source, xp FROM xp_events ORDER BY day, source")
+	if err != nil {
+		return nil, fmt.Errorf("query xp events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.XPEventModel
+	for rows.Next() {
+		var day string
+		var event models.XPEventModel
+		if err := rows.Scan(&day, &event.Source, &event.XP); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		event.Day = parseStoredDate(day)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return events, nil
+}
+
+func (r *xpRepository) ReplaceForDate(day time.Time, sources []string, events []models.XPEventModel) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, source := range sources {
+		if _, err := tx.Exec("DELETE FROM xp_events WHERE day = ? AND source = ?", day.Format(constnats.DateLayout), source); err != nil {
+			return fmt.Errorf("delete old xp events: %w", err)
+		}
+	}
+	if err := insertXPEvents(tx, events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *xpRepository) ReplaceSource(source string, events []models.XPEventModel) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM xp_events WHERE source = ?", source); err != nil {
+		return fmt.Errorf("delete old xp events: %w", err)
+	}
+	if err := insertXPEvents(tx, events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func insertXPEvents(tx DB, events []models.XPEventModel) error {
+	for _, event := range events {
+		if event.XP == 0 {
+			continue
+		}
+		_, err := tx.Exec("INSERT INTO xp_events (day, source, xp) VALUES (?, ?, ?)",
+			event.Day.Format(constnats.DateLayout), event.Source, event.XP)
+		if err != nil {
+			return fmt.Errorf("insert xp event %s: %w", event.Source, err)
+		}
+	}
+	return nil
+}
+
+func (r *xpRepository) LevelUps() ([]models.LevelUpModel, error) {
+	rows, err := r.db.Query("SELECT level, name, day FROM level_ups ORDER BY level")
+	if err != nil {
+		return nil, fmt.Errorf("query level ups: %w", err)
+	}
+	defer rows.Close()
+
+	var levelUps []models.LevelUpModel
+	for rows.Next() {
+		var day string
+		var levelUp models.LevelUpModel
+		if err := rows.Scan(&levelUp.Level, &levelUp.Name, &day); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		levelUp.Day = parseStoredDate(day)
+		levelUps = append(levelUps, levelUp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return levelUps, nil
+}
+
+func (r *xpRepository) ReplaceLevelUps(levelUps []models.LevelUpModel) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM level_ups"); err != nil {
+		return fmt.Errorf("delete old level ups: %w", err)
+	}
+	for _, levelUp := range levelUps {
+		_, err := tx.Exec("INSERT INTO level_ups (level, name, day) VALUES (?, ?, ?)",
+			levelUp.Level, levelUp.Name, levelUp.Day.Format(constnats.DateLayout))
+		if err != nil {
+			return fmt.Errorf("insert level up %d: %w", levelUp.Level, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *xpRepository) BackfillDays() ([]time.Time, error) {
+	return queryDays(r.db, "SELECT day FROM xp_backfill_days ORDER BY day")
+}
+
+func (r *xpRepository) RemoveBackfillDays(days []time.Time) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, day := range days {
+		if _, err := tx.Exec("DELETE FROM xp_backfill_days WHERE day = ?", day.Format(constnats.DateLayout)); err != nil {
+			return fmt.Errorf("delete xp backfill day: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"gomificator/internal/constnats"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -48,3 +51,18 @@ func FileExists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+// FileSHA256 returns the hex encoded SHA-256 of the file content
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
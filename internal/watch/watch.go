@@ -0,0 +1,46 @@
+// Package watch notifies about changes of files in a directory.
+package watch
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnsupported is returned by New on platforms without a native file watcher.
+// Callers are expected to fall back to polling.
+var ErrUnsupported = errors.New("watch: file events are not supported on this platform")
+
+// debounce merges bursts of events, e.g. an editor writing a file in chunks.
+const debounce = 500 * time.Millisecond
+
+// Watcher sends a value on Events after files in the watched directory were
+// written, created or moved in. Events is closed after Close.
+type Watcher interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// coalesce forwards raw events to out, at most once per debounce period.
+func coalesce(raw <-chan struct{}, out chan<- struct{}) {
+	defer close(out)
+	for range raw {
+		timer := time.NewTimer(debounce)
+	drain:
+		for {
+			select {
+			case _, ok := <-raw:
+				if !ok {
+					timer.Stop()
+					break drain
+				}
+			case <-timer.C:
+				break drain
+			}
+		}
+		select {
+		case out <- struct{}{}:
+		default:
+			// a notification is already pending
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package watch
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+type inotifyWatcher struct {
+	file   *os.File
+	events chan struct{}
+}
+
+// New watches dir with inotify.
+func New(dir string) (Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO|unix.IN_CREATE); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("inotify add watch %s: %w", dir, err)
+	}
+
+	w := &inotifyWatcher{
+		// non-blocking descriptor lets the runtime poller interrupt Read on Close
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+	}
+
+	raw := make(chan struct{})
+	go w.read(raw)
+	go coalesce(raw, w.events)
+
+	return w, nil
+}
+
+func (w *inotifyWatcher) read(raw chan<- struct{}) {
+	defer close(raw)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		if n > 0 {
+			raw <- struct{}{}
+		}
+	}
+}
+
+func (w *inotifyWatcher) Events() <-chan struct{} {
+	return w.events
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watch
+
+// New reports ErrUnsupported, only Linux has a native watcher so far.
+func New(dir string) (Watcher, error) {
+	return nil, ErrUnsupported
+}
@@ -17,20 +17,23 @@ func main() {
 		panic(err)
 	}
 
-	if !isStorageExists {
-
-		strg, err := storage.NewSqlliteStorage()
-		if err != nil {
-			panic(err)
-		}
+	strg, err := storage.NewSqlliteStorage()
+	if err != nil {
+		panic(err)
+	}
 
-		goose.SetDialect("sqlite3")
+	goose.SetDialect("sqlite3")
+	if !isStorageExists {
 		fmt.Println(">> first launch migrations:")
-		if err = storage.MigrateDb(strg); err != nil {
-			panic(err)
-		}
-		fmt.Println(">> end of first launch migrations\n\n\n")
-
+	} else {
+		// apply migrations added by updates silently
+		goose.SetLogger(goose.NopLogger())
+	}
+	if err = storage.MigrateDb(strg); err != nil {
+		panic(err)
+	}
+	if !isStorageExists {
+		fmt.Print(">> end of first launch migrations\n\n\n\n")
 	}
 
 	cmd.Execute()